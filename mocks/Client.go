@@ -1,166 +1,305 @@
-package mocks
-
-import (
-	"net/http"
-	"net/url"
-
-	"github.com/PuerkitoBio/goquery"
-	"github.com/stretchr/testify/mock"
-)
-
-// Client is an autogenerated mock type for the Client type
-type Client struct {
-	mock.Mock
-}
-
-// Post provides a mock function with given fields: _a0, _a1
-func (_m *Client) Post(_a0 string, _a1 url.Values) (*http.Response, error) {
-	ret := _m.Called(_a0, _a1)
-
-	var r0 *http.Response
-	if rf, ok := ret.Get(0).(func(string, url.Values) *http.Response); ok {
-		r0 = rf(_a0, _a1)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*http.Response)
-		}
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-// PostBytes provides a mock function with given fields: _a0
-func (_m *Client) PostBytes(_a0 string, _a1 url.Values) ([]byte, error) {
-	ret := _m.Called(_a0, _a1)
-
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(string, url.Values) []byte); ok {
-		r0 = rf(_a0, _a1)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
-		}
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-// Get provides a mock function with given fields: _a0
-func (_m *Client) Get(_a0 string) (*http.Response, error) {
-	ret := _m.Called(_a0)
-
-	var r0 *http.Response
-	if rf, ok := ret.Get(0).(func(string) *http.Response); ok {
-		r0 = rf(_a0)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*http.Response)
-		}
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-// GetBytes provides a mock function with given fields: _a0
-func (_m *Client) GetBytes(_a0 string) ([]byte, error) {
-	ret := _m.Called(_a0)
-
-	var r0 []byte
-	if rf, ok := ret.Get(0).(func(string) []byte); ok {
-		r0 = rf(_a0)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
-		}
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-// GetDoc provides a mock function with given fields: _a0
-func (_m *Client) GetDoc(_a0 string) (*goquery.Document, error) {
-	ret := _m.Called(_a0)
-
-	var r0 *goquery.Document
-	if rf, ok := ret.Get(0).(func(string) *goquery.Document); ok {
-		r0 = rf(_a0)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*goquery.Document)
-		}
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(_a0)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-// GetFind provides a mock function with given fields: _a0, _a1
-func (_m *Client) GetFind(_a0 string, _a1 string) (*goquery.Selection, error) {
-	ret := _m.Called(_a0, _a1)
-
-	var r0 *goquery.Selection
-	if rf, ok := ret.Get(0).(func(string, string) *goquery.Selection); ok {
-		r0 = rf(_a0, _a1)
-	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*goquery.Selection)
-		}
-	}
-
-	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(_a0, _a1)
-	} else {
-		r1 = ret.Error(1)
-	}
-
-	return r0, r1
-}
-
-// SocksEnabled provides a mock function with given fields:
-func (_m *Client) SocksEnabled() bool {
-	ret := _m.Called()
-
-	var r0 bool
-	if rf, ok := ret.Get(0).(func() bool); ok {
-		r0 = rf()
-	} else {
-		r0 = ret.Get(0).(bool)
-	}
-
-	return r0
-}
+package mocks
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/mock"
+)
+
+// Client is an autogenerated mock type for the Client type
+type Client struct {
+	mock.Mock
+}
+
+// Post provides a mock function with given fields: _a0, _a1
+func (_m *Client) Post(_a0 string, _a1 url.Values) (*http.Response, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *http.Response
+	if rf, ok := ret.Get(0).(func(string, url.Values) *http.Response); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*http.Response)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PostBytes provides a mock function with given fields: _a0
+func (_m *Client) PostBytes(_a0 string, _a1 url.Values) ([]byte, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(string, url.Values) []byte); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Get provides a mock function with given fields: _a0
+func (_m *Client) Get(_a0 string) (*http.Response, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *http.Response
+	if rf, ok := ret.Get(0).(func(string) *http.Response); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*http.Response)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBytes provides a mock function with given fields: _a0
+func (_m *Client) GetBytes(_a0 string) ([]byte, error) {
+	ret := _m.Called(_a0)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(string) []byte); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDoc provides a mock function with given fields: _a0
+func (_m *Client) GetDoc(_a0 string) (*goquery.Document, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *goquery.Document
+	if rf, ok := ret.Get(0).(func(string) *goquery.Document); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*goquery.Document)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFind provides a mock function with given fields: _a0, _a1
+func (_m *Client) GetFind(_a0 string, _a1 string) (*goquery.Selection, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *goquery.Selection
+	if rf, ok := ret.Get(0).(func(string, string) *goquery.Selection); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*goquery.Selection)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SocksEnabled provides a mock function with given fields:
+func (_m *Client) SocksEnabled() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// PostContext provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Client) PostContext(_a0 context.Context, _a1 string, _a2 url.Values) (*http.Response, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *http.Response
+	if rf, ok := ret.Get(0).(func(context.Context, string, url.Values) *http.Response); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*http.Response)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, url.Values) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PostBytesContext provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Client) PostBytesContext(_a0 context.Context, _a1 string, _a2 url.Values) ([]byte, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(context.Context, string, url.Values) []byte); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, url.Values) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetContext provides a mock function with given fields: _a0, _a1
+func (_m *Client) GetContext(_a0 context.Context, _a1 string) (*http.Response, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *http.Response
+	if rf, ok := ret.Get(0).(func(context.Context, string) *http.Response); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*http.Response)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBytesContext provides a mock function with given fields: _a0, _a1
+func (_m *Client) GetBytesContext(_a0 context.Context, _a1 string) ([]byte, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(context.Context, string) []byte); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDocContext provides a mock function with given fields: _a0, _a1
+func (_m *Client) GetDocContext(_a0 context.Context, _a1 string) (*goquery.Document, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *goquery.Document
+	if rf, ok := ret.Get(0).(func(context.Context, string) *goquery.Document); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*goquery.Document)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetFindContext provides a mock function with given fields: _a0, _a1, _a2
+func (_m *Client) GetFindContext(_a0 context.Context, _a1 string, _a2 string) (*goquery.Selection, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *goquery.Selection
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *goquery.Selection); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*goquery.Selection)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -1,202 +1,353 @@
-package scraper
-
-import (
-	"errors"
-	"fmt"
-	"io/ioutil"
-	"net"
-	"net/http"
-	"net/http/cookiejar"
-	"net/url"
-	"strings"
-	"time"
-	// "github.com/sethgrid/pester"
-	"golang.org/x/net/proxy"
-
-	"github.com/PuerkitoBio/goquery"
-)
-
-type Client interface {
-	Post(string, url.Values) (*http.Response, error)
-	PostBytes(string, url.Values) ([]byte, error)
-	Get(string) (*http.Response, error)
-	GetBytes(string) ([]byte, error)
-	SocksEnabled() bool
-	GetDoc(string) (*goquery.Document, error)
-	GetFind(string, string) (*goquery.Selection, error)
-}
-type DefaultClient struct {
-	Socks5Proxy  string
-	Encoding     string
-	DialTimeout  time.Duration
-	ReadTimeout  time.Duration
-	Retry        int
-	socksEnabled bool
-	Client       *http.Client
-}
-
-type HTTPError struct {
-	code int
-}
-
-func (h HTTPError) Code() int {
-	return h.code
-}
-func (h HTTPError) Error() string {
-	return fmt.Sprintf("%d", h.code)
-}
-
-func NewDefaultClient(client *DefaultClient) (Client, error) {
-	if client == nil {
-		client = new(DefaultClient)
-	}
-	if client.DialTimeout == 0 {
-		client.DialTimeout = time.Second * 10
-	}
-	if client.ReadTimeout == 0 {
-		client.ReadTimeout = time.Second * 10
-	}
-	if client.Encoding == "" {
-		client.Encoding = "utf-8"
-	}
-	if client.Retry == 0 {
-		client.Retry = 3
-	}
-	transport := &http.Transport{
-		Dial: func(network, addr string) (net.Conn, error) {
-			return net.DialTimeout(network, addr, client.DialTimeout)
-		},
-		Proxy: http.ProxyFromEnvironment,
-		ResponseHeaderTimeout: client.ReadTimeout,
-	}
-	if client.Socks5Proxy != "" {
-		p, err := proxy.SOCKS5("tcp", client.Socks5Proxy, nil, proxy.Direct)
-		if err != nil {
-			return nil, err
-		}
-		client.socksEnabled = true
-		transport.Dial = p.Dial
-	}
-	jar, err := cookiejar.New(nil)
-	if err != nil {
-		return nil, err
-	}
-	client.Client = &http.Client{
-		Transport: transport,
-		Jar:       jar,
-	}
-	return client, nil
-}
-func (c *DefaultClient) Post(url string, form url.Values) (*http.Response, error) {
-	retry := c.Retry
-	for {
-		if req, err := http.NewRequest("POST", url, strings.NewReader(form.Encode())); err == nil {
-			req.Header.Set("content-type", "application/x-www-form-urlencoded")
-			req.Header.Add("User-Agent", `Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.27 Safari/537.36`)
-			resp, err := c.Client.Do(req)
-			if err != nil {
-				if retry == 0 {
-					return nil, err
-				}
-				retry--
-				continue
-			}
-			if resp.StatusCode == 200 {
-				return resp, nil
-			} else {
-				return nil, HTTPError{resp.StatusCode}
-			}
-		} else {
-			return nil, err
-		}
-	}
-}
-
-func (c *DefaultClient) PostBytes(url string, form url.Values) ([]byte, error) {
-	retry := c.Retry
-	for {
-		resp, err := c.Client.Post(url, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
-		if err != nil {
-			if retry == 0 {
-				return nil, err
-			}
-			retry--
-			continue
-		} else {
-			defer resp.Body.Close()
-			contents, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return nil, err
-			}
-			return contents, nil
-		}
-	}
-}
-func (c *DefaultClient) Get(url string) (*http.Response, error) {
-	retry := c.Retry
-	for {
-		req, err := http.NewRequest("GET", url, nil)
-		if err == nil {
-			req.Header.Add("User-Agent", `Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.27 Safari/537.36`)
-			resp, err := c.Client.Do(req)
-			if err != nil {
-				if retry == 0 {
-					return nil, err
-				}
-				retry--
-				continue
-			}
-			if resp.StatusCode == 200 {
-				return resp, nil
-			}
-			return nil, HTTPError{resp.StatusCode}
-		}
-		return nil, err
-	}
-}
-
-func (c *DefaultClient) GetBytes(url string) ([]byte, error) {
-	retry := c.Retry
-	for {
-		resp, err := c.Client.Get(url)
-		if err != nil {
-			if retry == 0 {
-				return nil, err
-			}
-			retry--
-			continue
-		}
-		defer resp.Body.Close()
-		contents, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		return contents, nil
-	}
-}
-
-func (c *DefaultClient) SocksEnabled() bool {
-	return c.socksEnabled
-}
-
-// TODO: Handle http error codes properly for 400, 429, 500
-func (c *DefaultClient) GetDoc(url string) (*goquery.Document, error) {
-	resp, err := c.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		return goquery.NewDocumentFromResponse(resp)
-	}
-	b, _ := ioutil.ReadAll(resp.Body)
-	return nil, errors.New("unable to retrieve doc " + resp.Status + " " + string(b))
-}
-
-func (c *DefaultClient) GetFind(url string, selector string) (*goquery.Selection, error) {
-	doc, err := c.GetDoc(url)
-	if err != nil {
-		return nil, err
-	}
-	return doc.Find(selector), nil
-}
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+	// "github.com/sethgrid/pester"
+	"golang.org/x/net/proxy"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type Client interface {
+	Post(string, url.Values) (*http.Response, error)
+	PostBytes(string, url.Values) ([]byte, error)
+	Get(string) (*http.Response, error)
+	GetBytes(string) ([]byte, error)
+	SocksEnabled() bool
+	GetDoc(string) (*goquery.Document, error)
+	GetFind(string, string) (*goquery.Selection, error)
+	PostContext(context.Context, string, url.Values) (*http.Response, error)
+	PostBytesContext(context.Context, string, url.Values) ([]byte, error)
+	GetContext(context.Context, string) (*http.Response, error)
+	GetBytesContext(context.Context, string) ([]byte, error)
+	GetDocContext(context.Context, string) (*goquery.Document, error)
+	GetFindContext(context.Context, string, string) (*goquery.Selection, error)
+}
+type DefaultClient struct {
+	Socks5Proxy string
+	Encoding    string
+	DialTimeout time.Duration
+	ReadTimeout time.Duration
+	// Retry is the most times a request is retried when the client has no RetryPolicy
+	Retry int
+	// RetryPolicy decides which failed requests are retried and how long to wait before, it
+	// defaults to DefaultRetryPolicy with Retry retries which does not retry POST requests
+	RetryPolicy RetryPolicy
+	// RateLimit limits the requests made to each host, NewDefaultClient creates Limiter from it
+	RateLimit RateLimit
+	// Limiter delays requests to each host, it can be shared by clients to limit them together
+	Limiter *RateLimiter
+	// Header is sent with every request, WithHeader overrides it for the requests made with a context
+	Header http.Header
+	// UserAgents picks the User-Agent of each request, without it the User-Agent of Header or
+	// DefaultUserAgent is used
+	UserAgents UserAgentRotator
+	// Robots makes the client refuse urls disallowed by robots.txt with a *RobotsError and pace
+	// requests by the Crawl-delay of each host, nil ignores robots.txt
+	Robots       *RobotsPolicy
+	socksEnabled bool
+	Client       *http.Client
+}
+
+// maxDrainSize is the most of the body of a failed response which is read so its connection can be reused
+const maxDrainSize = 64 * 1024
+
+// maxErrorBodySize is the most of the body of a failed response which is kept in its HTTPError
+const maxErrorBodySize = 1024
+
+// HTTPError is the error of a request which got a response with a status other than 2xx
+type HTTPError struct {
+	Method string
+	URL    string
+	// StatusCode is the status of the response, like 404
+	StatusCode int
+	// Status is the status line of the response, like "404 Not Found"
+	Status string
+	Header http.Header
+	// Body is the start of the response body, at most 1KiB
+	Body string
+	// Attempts is how many times the request was made
+	Attempts int
+}
+
+// newHTTPError makes the error of resp, it reads the start of the body but does not close it
+func newHTTPError(resp *http.Response, attempts int) HTTPError {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	err := HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       string(body),
+		Attempts:   attempts,
+	}
+	if resp.Request != nil {
+		err.Method, err.URL = resp.Request.Method, resp.Request.URL.String()
+	}
+	return err
+}
+
+// Code returns the status code of the response
+func (h HTTPError) Code() int {
+	return h.StatusCode
+}
+
+func (h HTTPError) Error() string {
+	status := h.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", h.StatusCode, http.StatusText(h.StatusCode))
+	}
+	msg := status
+	if h.URL != "" {
+		msg = fmt.Sprintf("%s %s: %s", h.Method, h.URL, status)
+	}
+	if h.Attempts > 1 {
+		msg += fmt.Sprintf(" after %d attempts", h.Attempts)
+	}
+	if body := strings.TrimSpace(h.Body); body != "" {
+		msg += ": " + body
+	}
+	return msg
+}
+
+// IsRetryable reports whether the request can succeed when it is made again later, for a 429 or a 5xx status
+func (h HTTPError) IsRetryable() bool {
+	return retryableStatus(h.StatusCode)
+}
+
+// IsBlocked reports whether the server refused the client, for a 403, 429 or 451 status
+func (h HTTPError) IsBlocked() bool {
+	switch h.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusUnavailableForLegalReasons:
+		return true
+	}
+	return false
+}
+
+// IsNotFound reports whether the url does not exist, for a 404 or 410 status
+func (h HTTPError) IsNotFound() bool {
+	return h.StatusCode == http.StatusNotFound || h.StatusCode == http.StatusGone
+}
+
+func NewDefaultClient(client *DefaultClient) (Client, error) {
+	if client == nil {
+		client = new(DefaultClient)
+	}
+	if client.DialTimeout == 0 {
+		client.DialTimeout = time.Second * 10
+	}
+	if client.ReadTimeout == 0 {
+		client.ReadTimeout = time.Second * 10
+	}
+	if client.Encoding == "" {
+		client.Encoding = "utf-8"
+	}
+	if client.Retry == 0 {
+		client.Retry = 3
+	}
+	if client.RetryPolicy == nil {
+		policy := DefaultRetryPolicy
+		policy.MaxRetries = client.Retry
+		client.RetryPolicy = policy
+	}
+	if client.Limiter == nil && (client.RateLimit.enabled() || client.Robots != nil) {
+		client.Limiter = NewRateLimiter(client.RateLimit)
+	}
+	transport := &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, client.DialTimeout)
+		},
+		Proxy: http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: client.ReadTimeout,
+	}
+	if client.Socks5Proxy != "" {
+		p, err := proxy.SOCKS5("tcp", client.Socks5Proxy, nil, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		client.socksEnabled = true
+		transport.Dial = p.Dial
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client.Client = &http.Client{
+		Transport: transport,
+		Jar:       jar,
+	}
+	return client, nil
+}
+func (c *DefaultClient) Post(url string, form url.Values) (*http.Response, error) {
+	return c.PostContext(context.Background(), url, form)
+}
+
+// PostContext posts a form to url, the request is aborted when ctx is done
+func (c *DefaultClient) PostContext(ctx context.Context, url string, form url.Values) (*http.Response, error) {
+	return c.send(ctx, func() (*http.Request, error) {
+		return c.newRequest(ctx, "POST", url, form)
+	})
+}
+
+func (c *DefaultClient) PostBytes(url string, form url.Values) ([]byte, error) {
+	return c.PostBytesContext(context.Background(), url, form)
+}
+
+// PostBytesContext posts a form to url and returns the response body, the request is aborted when ctx is done
+func (c *DefaultClient) PostBytesContext(ctx context.Context, url string, form url.Values) ([]byte, error) {
+	return readBody(c.send(ctx, func() (*http.Request, error) {
+		return c.newRequest(ctx, "POST", url, form)
+	}))
+}
+func (c *DefaultClient) Get(url string) (*http.Response, error) {
+	return c.GetContext(context.Background(), url)
+}
+
+// GetContext gets url, the request is aborted when ctx is done
+func (c *DefaultClient) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	return c.send(ctx, func() (*http.Request, error) {
+		return c.newRequest(ctx, "GET", url, nil)
+	})
+}
+
+func (c *DefaultClient) GetBytes(url string) ([]byte, error) {
+	return c.GetBytesContext(context.Background(), url)
+}
+
+// GetBytesContext gets url and returns the response body, the request is aborted when ctx is done
+func (c *DefaultClient) GetBytesContext(ctx context.Context, url string) ([]byte, error) {
+	return readBody(c.send(ctx, func() (*http.Request, error) {
+		return c.newRequest(ctx, "GET", url, nil)
+	}))
+}
+
+// newRequest makes a request with the headers of the client and ctx, a form is sent url encoded
+func (c *DefaultClient) newRequest(ctx context.Context, method, url string, form url.Values) (*http.Request, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	c.setHeaders(req)
+	return req, nil
+}
+
+// readBody reads and closes the body of resp
+func readBody(resp *http.Response, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// send makes the request built by newReq and makes it again while the retry policy allows it. It
+// returns the response when its status is 2xx and otherwise an HTTPError or the transport error
+func (c *DefaultClient) send(ctx context.Context, newReq func() (*http.Request, error)) (*http.Response, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.do(ctx, req)
+		if err == nil && resp.StatusCode/100 == 2 {
+			return resp, nil
+		}
+		if err != nil && (ctx.Err() != nil || errors.Is(err, ErrDisallowed)) {
+			return nil, err
+		}
+		delay, retry := c.RetryPolicy.Retry(req, attempt, time.Since(start), resp, err)
+		if resp != nil {
+			err = newHTTPError(resp, attempt)
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainSize))
+			resp.Body.Close()
+		}
+		if !retry {
+			return nil, err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// do sends req once robots.txt and the rate limiter allow a request to its host
+func (c *DefaultClient) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if c.Robots != nil {
+		delay, err := c.Robots.Check(ctx, doerFunc(c.robotsDo), req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if delay > 0 && c.Limiter != nil {
+			c.Limiter.SetHostDelay(req.URL.Host, delay)
+		}
+	}
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+// robotsDo sends a robots.txt request with the headers of the client once the rate limiter allows it
+func (c *DefaultClient) robotsDo(req *http.Request) (*http.Response, error) {
+	c.setHeaders(req)
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *DefaultClient) SocksEnabled() bool {
+	return c.socksEnabled
+}
+
+func (c *DefaultClient) GetDoc(url string) (*goquery.Document, error) {
+	return c.GetDocContext(context.Background(), url)
+}
+
+// GetDocContext gets url as a goquery document, the request is aborted when ctx is done
+func (c *DefaultClient) GetDocContext(ctx context.Context, url string) (*goquery.Document, error) {
+	resp, err := c.GetContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return goquery.NewDocumentFromResponse(resp)
+}
+
+func (c *DefaultClient) GetFind(url string, selector string) (*goquery.Selection, error) {
+	return c.GetFindContext(context.Background(), url, selector)
+}
+
+// GetFindContext finds selector in the document at url, the request is aborted when ctx is done
+func (c *DefaultClient) GetFindContext(ctx context.Context, url string, selector string) (*goquery.Selection, error) {
+	doc, err := c.GetDocContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Find(selector), nil
+}
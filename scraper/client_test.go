@@ -0,0 +1,63 @@
+package scraper
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHTTPError(t *testing.T) {
+	Convey("given a server which blocks the client", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Reason", "bot")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("access denied " + strings.Repeat("x", 2*maxErrorBodySize)))
+		}))
+		defer server.Close()
+		client, err := NewDefaultClient(nil)
+		So(err, ShouldBeNil)
+		_, err = client.GetDoc(server.URL + "/page")
+		var httpErr HTTPError
+		So(errors.As(err, &httpErr), ShouldBeTrue)
+		Convey("the error describes the request and the response", func() {
+			So(httpErr.Method, ShouldEqual, "GET")
+			So(httpErr.URL, ShouldEqual, server.URL+"/page")
+			So(httpErr.Code(), ShouldEqual, 403)
+			So(httpErr.Status, ShouldEqual, "403 Forbidden")
+			So(httpErr.Header.Get("X-Reason"), ShouldEqual, "bot")
+			So(httpErr.Attempts, ShouldEqual, 1)
+			So(httpErr.Body, ShouldStartWith, "access denied")
+			So(len(httpErr.Body), ShouldEqual, maxErrorBodySize)
+			So(err.Error(), ShouldStartWith, "GET "+server.URL+"/page: 403 Forbidden: access denied")
+		})
+		Convey("the error is classified as blocked", func() {
+			So(httpErr.IsBlocked(), ShouldBeTrue)
+			So(httpErr.IsRetryable(), ShouldBeFalse)
+			So(httpErr.IsNotFound(), ShouldBeFalse)
+		})
+	})
+	Convey("given http errors of several statuses", t, func() {
+		for _, c := range []struct {
+			code                         int
+			retryable, blocked, notFound bool
+		}{
+			{404, false, false, true},
+			{410, false, false, true},
+			{429, true, true, false},
+			{451, false, true, false},
+			{500, true, false, false},
+			{503, true, false, false},
+			{400, false, false, false},
+		} {
+			err := HTTPError{StatusCode: c.code}
+			So(err.IsRetryable(), ShouldEqual, c.retryable)
+			So(err.IsBlocked(), ShouldEqual, c.blocked)
+			So(err.IsNotFound(), ShouldEqual, c.notFound)
+		}
+		So(HTTPError{StatusCode: 429, Attempts: 3}.Error(), ShouldEqual, "429 Too Many Requests after 3 attempts")
+	})
+}
@@ -0,0 +1,375 @@
+package scraper
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// grappleTag is a parsed `grapple:"id=...,css=...,selector=...,attr=...,re=...,type=...,key=...,val=..."` struct tag,
+// key and val are the css paths of the keys and values of a property_array and selector is the kind of css
+type grappleTag struct {
+	id       string
+	css      string
+	selector string
+	attr     string
+	re       string
+	typ      string
+	key      string
+	val      string
+	skip     bool
+}
+
+var grappleTagKeys = []string{"id", "css", "selector", "attr", "re", "type", "key", "val"}
+
+// parseGrappleTag parses a grapple struct tag, a comma that does not start a new key belongs to
+// the previous value so selectors like "h1, h2" need no escaping
+func parseGrappleTag(tag string) (t grappleTag) {
+	if tag == "-" {
+		t.skip = true
+		return
+	}
+	var key string
+	values := map[string]string{}
+	for _, part := range strings.Split(tag, ",") {
+		if k, v, ok := strings.Cut(part, "="); ok && isGrappleTagKey(k) {
+			key = k
+			values[key] = v
+			continue
+		}
+		if key != "" {
+			values[key] += "," + part
+		}
+	}
+	t.id, t.css, t.attr, t.re, t.typ = values["id"], values["css"], values["attr"], values["re"], values["type"]
+	t.key, t.val, t.selector = values["key"], values["val"], values["selector"]
+	return
+}
+
+func isGrappleTagKey(key string) bool {
+	for _, k := range grappleTagKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldId returns the row id of a struct field, the grapple id, then the json name, then the field name
+func fieldId(field reflect.StructField, tag grappleTag) string {
+	if tag.id != "" {
+		return tag.id
+	}
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+func structType(v interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T is not a struct", ErrInvalidValue, v)
+	}
+	return t, nil
+}
+
+// SchemaFromStruct builds a schema from the grapple tags of the struct type of v. Only fields
+// with a css tag are extracted, the schema type is taken from the tag or the go type of the field
+func SchemaFromStruct(v interface{}) (*Schema, error) {
+	t, err := structType(v)
+	if err != nil {
+		return nil, err
+	}
+	schema := &Schema{CssPath: []string{""}}
+	schema.Properties, err = structProperties(t)
+	if err != nil {
+		return nil, err
+	}
+	return schema, schema.Validate()
+}
+
+func structProperties(t reflect.Type) ([]Schema, error) {
+	var properties []Schema
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseGrappleTag(field.Tag.Get("grapple"))
+		if field.PkgPath != "" || tag.skip || tag.css == "" {
+			continue
+		}
+		property := Schema{Id: fieldId(field, tag), CssPath: []string{tag.css, tag.attr}, Selector: tag.selector, Type: tag.typ}
+		if tag.re != "" {
+			property.CssPath = append(property.CssPath, tag.re)
+		} else if tag.attr == "" {
+			property.CssPath = property.CssPath[:1]
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if property.Type == "" {
+			switch ft.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				property.Type = INT_PROPERTY
+			case reflect.Float32, reflect.Float64:
+				property.Type = FLOAT_PROPERTY
+			case reflect.Bool:
+				property.Type = BOOL_PROPERTY
+			case reflect.Slice:
+				property.Type = ARRAY_PROPERTY
+			case reflect.Map:
+				property.Type = PROPERTY_ARRAY
+			case reflect.Struct:
+				property.Type = OBJECT_PROPERTY
+			default:
+				property.Type = STRING_PROPERTY
+			}
+		}
+		if property.Type == PROPERTY_ARRAY {
+			property.KeyPath = []string{tag.key}
+			property.ValPath = []string{tag.val}
+		}
+		if property.Type == OBJECT_PROPERTY {
+			if ft.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("%w: field %s of type object must be a struct", ErrInvalidValue, field.Name)
+			}
+			nested, err := structProperties(ft)
+			if err != nil {
+				return nil, err
+			}
+			property.Properties = nested
+		}
+		properties = append(properties, property)
+	}
+	return properties, nil
+}
+
+// DecodeSelection extracts the struct pointed to by v from node using the grapple tags of its fields
+func DecodeSelection(node *goquery.Selection, v interface{}) error {
+	schema, err := SchemaFromStruct(v)
+	if err != nil {
+		return err
+	}
+	row := NewExtractor(schema).RowResult(node, schema, 0)
+	if err := DecodeRow(row.Data, v); err != nil {
+		return err
+	}
+	if len(row.Errors) > 0 {
+		return row.Errors
+	}
+	return nil
+}
+
+// DecodeRow fills the struct pointed to by v from row, each field is filled from the row value
+// with its id. Values are converted to the type of their field and every field that could not be
+// converted is reported in the returned DecodeErrors
+func DecodeRow(row map[string]interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T is not a pointer to a struct", ErrInvalidValue, v)
+	}
+	if errs := decodeStruct(row, rv.Elem(), 0, ""); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DecodeRows fills the slice of structs pointed to by v from rows, rows can be maps or structs
+// already of the slice element type
+func DecodeRows(rows []interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("%w: %T is not a pointer to a slice", ErrInvalidValue, v)
+	}
+	slice := rv.Elem()
+	var errs DecodeErrors
+	for i, row := range rows {
+		item := reflect.New(slice.Type().Elem()).Elem()
+		if err := decodeValue(row, item); err != nil {
+			errs = append(errs, &DecodeError{Row: i, Err: err})
+			continue
+		}
+		slice.Set(reflect.Append(slice, item))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func decodeStruct(row map[string]interface{}, rv reflect.Value, index int, prefix string) (errs DecodeErrors) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := parseGrappleTag(field.Tag.Get("grapple"))
+		if field.PkgPath != "" || tag.skip {
+			continue
+		}
+		id := fieldId(field, tag)
+		val, ok := row[id]
+		if !ok {
+			val, ok = lookupFold(row, id)
+		}
+		if !ok || val == nil {
+			continue
+		}
+		fv := rv.Field(i)
+		if nested, isMap := val.(map[string]interface{}); isMap && indirectType(fv.Type()).Kind() == reflect.Struct {
+			errs = append(errs, decodeStruct(nested, allocate(fv), index, prefix+field.Name+".")...)
+			continue
+		}
+		if err := decodeValue(val, fv); err != nil {
+			errs = append(errs, &DecodeError{Row: index, Field: prefix + field.Name, Id: id, Err: err})
+		}
+	}
+	return
+}
+
+func lookupFold(row map[string]interface{}, id string) (interface{}, bool) {
+	for k, v := range row {
+		if strings.EqualFold(k, id) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// allocate returns the value pointed to by fv, allocating nil pointers on the way
+func allocate(fv reflect.Value) reflect.Value {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+	return fv
+}
+
+// isScalar reports whether a value of kind is a string, number or bool which can be written as a string
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// decodeValue converts val to the type of fv and sets it
+func decodeValue(val interface{}, fv reflect.Value) error {
+	if val == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Type().AssignableTo(fv.Type()) {
+		fv.Set(rv)
+		return nil
+	}
+	fv = allocate(fv)
+	if rv.Type().AssignableTo(fv.Type()) {
+		fv.Set(rv)
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		if _, ok := val.(fmt.Stringer); !ok && !isScalar(rv.Kind()) {
+			return fmt.Errorf("%w: %T can not be decoded into %s", ErrInvalidValue, val, fv.Type())
+		}
+		fv.SetString(fmt.Sprint(val))
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64 {
+			f := rv.Float()
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || fv.OverflowInt(int64(f)) {
+				return fmt.Errorf("%w: %v is not a valid %s", ErrInvalidValue, f, fv.Type())
+			}
+			fv.SetInt(int64(f))
+			return nil
+		}
+		i, err := strconv.ParseInt(strings.TrimSpace(fmt.Sprint(val)), 10, 64)
+		if err != nil || fv.OverflowInt(i) {
+			return fmt.Errorf("%w: %q is not a valid %s", ErrInvalidValue, fmt.Sprint(val), fv.Type())
+		}
+		fv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(fmt.Sprint(val)), 10, 64)
+		if err != nil || fv.OverflowUint(u) {
+			return fmt.Errorf("%w: %q is not a valid %s", ErrInvalidValue, fmt.Sprint(val), fv.Type())
+		}
+		fv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(val)), 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a valid %s", ErrInvalidValue, fmt.Sprint(val), fv.Type())
+		}
+		fv.SetFloat(f)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(fmt.Sprint(val)))
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a valid %s", ErrInvalidValue, fmt.Sprint(val), fv.Type())
+		}
+		fv.SetBool(b)
+		return nil
+	case reflect.Struct:
+		row, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: %T can not be decoded into %s", ErrInvalidValue, val, fv.Type())
+		}
+		if errs := decodeStruct(row, fv, 0, ""); len(errs) > 0 {
+			return errs
+		}
+		return nil
+	case reflect.Slice:
+		if rv.Kind() != reflect.Slice {
+			rv = reflect.ValueOf([]interface{}{val})
+		}
+		slice := reflect.MakeSlice(fv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := decodeValue(rv.Index(i).Interface(), slice.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Map:
+		if rv.Kind() != reflect.Map || fv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%w: %T can not be decoded into %s", ErrInvalidValue, val, fv.Type())
+		}
+		m := reflect.MakeMapWithSize(fv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			item := reflect.New(fv.Type().Elem()).Elem()
+			if err := decodeValue(iter.Value().Interface(), item); err != nil {
+				return fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+			m.SetMapIndex(reflect.ValueOf(fmt.Sprint(iter.Key().Interface())).Convert(fv.Type().Key()), item)
+		}
+		fv.Set(m)
+		return nil
+	case reflect.Interface:
+		if rv.Type().Implements(fv.Type()) {
+			fv.Set(rv)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %T can not be decoded into %s", ErrInvalidValue, val, fv.Type())
+}
@@ -0,0 +1,177 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type taggedProduct struct {
+	Name   string            `grapple:"css=h2 a"`
+	Link   string            `grapple:"id=url,css=h2 a,attr=href"`
+	ID     int               `grapple:"css=h2 a,attr=href,re=/p/(\\d+)"`
+	Price  float64           `grapple:"css=.price"`
+	Tags   []string          `grapple:"css=.tags li, .missing"`
+	Detail *productDetail    `grapple:"css=dl"`
+	Props  map[string]string `grapple:"css=dt,key=b,val=i"`
+	Note   string
+}
+
+type productDetail struct {
+	Size int `grapple:"css=dt:first-child i"`
+}
+
+type flagProduct struct {
+	Price float64 `grapple:"css=.price"`
+	Sale  bool    `grapple:"css=.sale"`
+}
+
+type rowProduct struct {
+	Title   string `json:"title"`
+	Price   int
+	Rating  float64 `grapple:"id=stars"`
+	InStock bool    `grapple:"id=in_stock"`
+	Seller  struct {
+		Name string `json:"name"`
+	} `json:"seller"`
+	Ignored string `grapple:"-"`
+}
+
+func TestParseGrappleTag(t *testing.T) {
+	Convey("commas that do not start a key should belong to the previous value", t, func() {
+		tag := parseGrappleTag("css=h1, h2,attr=title,re=(\\d+),(\\d+)")
+		So(tag.css, ShouldEqual, "h1, h2")
+		So(tag.attr, ShouldEqual, "title")
+		So(tag.re, ShouldEqual, "(\\d+),(\\d+)")
+		So(parseGrappleTag("-").skip, ShouldBeTrue)
+	})
+}
+
+func TestDecodeSelection(t *testing.T) {
+	Convey("given a struct with grapple tags", t, func() {
+		node := productDoc().Find(".product").First()
+		Convey("its schema should follow the tags and field types", func() {
+			schema, err := SchemaFromStruct(&taggedProduct{})
+			So(err, ShouldBeNil)
+			So(len(schema.Properties), ShouldEqual, 7)
+			So(schema.Properties[1], ShouldResemble, Schema{Id: "url", CssPath: []string{"h2 a", "href"}, Type: STRING_PROPERTY})
+			So(schema.Properties[2].Type, ShouldEqual, INT_PROPERTY)
+			So(schema.Properties[3].Type, ShouldEqual, FLOAT_PROPERTY)
+			So(schema.Properties[5].Type, ShouldEqual, OBJECT_PROPERTY)
+		})
+		Convey("float and bool fields should get float and bool properties", func() {
+			schema, err := SchemaFromStruct(flagProduct{})
+			So(err, ShouldBeNil)
+			So(schema.Properties[0].Type, ShouldEqual, FLOAT_PROPERTY)
+			So(schema.Properties[1].Type, ShouldEqual, BOOL_PROPERTY)
+		})
+		Convey("decoding a node should fill every tagged field", func() {
+			var p taggedProduct
+			So(DecodeSelection(node, &p), ShouldBeNil)
+			So(p.Name, ShouldEqual, "Blue Shoe")
+			So(p.Link, ShouldEqual, "/p/1")
+			So(p.ID, ShouldEqual, 1)
+			So(p.Price, ShouldEqual, 42)
+			So(p.Tags, ShouldResemble, []string{"shoes", "blue"})
+			So(p.Detail, ShouldResemble, &productDetail{Size: 10})
+			So(p.Props, ShouldResemble, map[string]string{"size": "10", "colour_name": "Blue"})
+		})
+		Convey("missing fields should be reported", func() {
+			var p taggedProduct
+			err := DecodeSelection(productDoc().Find(".product").Last(), &p)
+			So(errors.Is(err, ErrFieldNotFound), ShouldBeTrue)
+			So(p.Name, ShouldEqual, "Red Hat")
+		})
+	})
+}
+
+func TestDecodeRow(t *testing.T) {
+	Convey("given a row", t, func() {
+		row := map[string]interface{}{
+			"title":    "Kettle",
+			"price":    "15",
+			"stars":    "4.5",
+			"in_stock": "true",
+			"seller":   map[string]interface{}{"name": "Acme"},
+			"Ignored":  "x",
+		}
+		Convey("decoding it should map ids to fields", func() {
+			var p rowProduct
+			So(DecodeRow(row, &p), ShouldBeNil)
+			So(p.Title, ShouldEqual, "Kettle")
+			So(p.Price, ShouldEqual, 15)
+			So(p.Rating, ShouldEqual, 4.5)
+			So(p.InStock, ShouldBeTrue)
+			So(p.Seller.Name, ShouldEqual, "Acme")
+			So(p.Ignored, ShouldBeEmpty)
+		})
+		Convey("values that can not be converted should be reported per field", func() {
+			row["price"] = "cheap"
+			row["in_stock"] = "maybe"
+			var p rowProduct
+			err := DecodeRow(row, &p)
+			So(errors.Is(err, ErrInvalidValue), ShouldBeTrue)
+			var errs DecodeErrors
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(len(errs), ShouldEqual, 2)
+			So(errs[0].Field, ShouldEqual, "Price")
+			So(errs[1].Id, ShouldEqual, "in_stock")
+			So(p.Title, ShouldEqual, "Kettle")
+		})
+		Convey("floats which are not integers or overflow should be reported per field", func() {
+			var p struct {
+				Count int
+				Small int8
+				Whole int
+			}
+			err := DecodeRow(map[string]interface{}{"count": 4.5, "small": float64(300), "whole": float64(12)}, &p)
+			var errs DecodeErrors
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(len(errs), ShouldEqual, 2)
+			So(errs[0].Field, ShouldEqual, "Count")
+			So(errs[1].Field, ShouldEqual, "Small")
+			So(errors.Is(errs[0], ErrInvalidValue), ShouldBeTrue)
+			So(p.Whole, ShouldEqual, 12)
+		})
+		Convey("maps, slices and structs should not be decoded into strings", func() {
+			var p struct {
+				Title  string
+				Tags   string
+				Price  string
+				Sku    string
+				Weight string
+			}
+			err := DecodeRow(map[string]interface{}{
+				"title":  map[string]interface{}{"a": 1},
+				"tags":   []string{"a"},
+				"price":  Money{Amount: "12.50", Code: "USD"},
+				"sku":    json.Number("1042"),
+				"weight": 1.5,
+			}, &p)
+			var errs DecodeErrors
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(len(errs), ShouldEqual, 3)
+			So(errs[0].Field, ShouldEqual, "Title")
+			So(errs[1].Field, ShouldEqual, "Tags")
+			So(errs[2].Field, ShouldEqual, "Price")
+			So(errors.Is(errs[2], ErrInvalidValue), ShouldBeTrue)
+			So(p.Sku, ShouldEqual, "1042")
+			So(p.Weight, ShouldEqual, "1.5")
+		})
+		Convey("decoding into a non struct should fail", func() {
+			var s string
+			So(errors.Is(DecodeRow(row, &s), ErrInvalidValue), ShouldBeTrue)
+		})
+		Convey("decoding rows should fill a slice", func() {
+			var products []rowProduct
+			err := DecodeRows([]interface{}{row, map[string]interface{}{"title": "Pan"}, "bad"}, &products)
+			So(len(products), ShouldEqual, 2)
+			So(products[1].Title, ShouldEqual, "Pan")
+			var errs DecodeErrors
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(errs[0].Row, ShouldEqual, 2)
+		})
+	})
+}
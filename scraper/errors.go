@@ -1,7 +1,155 @@
-package scraper
-
-import (
-	"errors"
-)
-
-var ErrNoSchema = errors.New("no schema")
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNoSchema = errors.New("no schema")
+
+// ErrFieldNotFound is the cause of an ExtractError when a selector matched nothing
+var ErrFieldNotFound = errors.New("field not found")
+
+// ErrInvalidSelector is the cause of an ExtractError when a schema path can not be used
+var ErrInvalidSelector = errors.New("invalid selector")
+
+// ErrInvalidValue is the cause of an ExtractError when a value can not be converted to the type of its property
+var ErrInvalidValue = errors.New("invalid value")
+
+// ErrInvalidTransform is the cause of a SchemaError or ExtractError when a transform is not registered
+// or its argument can not be used
+var ErrInvalidTransform = errors.New("invalid transform")
+
+// ErrScript is the cause of an ExtractError or SchemaError when a schema script can not be compiled or throws
+var ErrScript = errors.New("script failed")
+
+// ErrScriptTimeout is the cause of an ExtractError when a schema script runs longer than the script timeout
+var ErrScriptTimeout = errors.New("script timed out")
+
+// ErrDisallowed is the cause of a *RobotsError, the error of a request to a url which robots.txt disallows
+var ErrDisallowed = errors.New("disallowed")
+
+// ExtractError describes why a schema property could not be extracted
+type ExtractError struct {
+	// Id is the id of the schema property
+	Id string
+	// CssPath is the css path of the schema property
+	CssPath []string
+	// Row is the index of the row the property belongs to
+	Row int
+	// Err is the cause, it wraps ErrFieldNotFound, ErrInvalidSelector or ErrInvalidValue when the
+	// field was absent, the selector could not be compiled or the value could not be converted and
+	// ErrScript or ErrScriptTimeout when the script of the property failed
+	Err error
+}
+
+func (e *ExtractError) Error() string {
+	return fmt.Sprintf("row %d: %s %v: %v", e.Row, e.Id, e.CssPath, e.Err)
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// ExtractErrors is a list of errors found while extracting a row
+type ExtractErrors []*ExtractError
+
+func (e ExtractErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any error in the list matches target
+func (e ExtractErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnknownType is the cause of a SchemaError when a schema type is neither built in nor a custom type
+var ErrUnknownType = errors.New("unknown type")
+
+// SchemaError is a problem found in a schema, Path is the json path of the problem in the schema
+type SchemaError struct {
+	Path string
+	Err  error
+}
+
+func (e *SchemaError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaErrors is every problem found in a schema
+type SchemaErrors []*SchemaError
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any error in the list matches target
+func (e SchemaErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeError describes a row value which could not be decoded into a struct field
+type DecodeError struct {
+	// Row is the index of the row
+	Row int
+	// Field is the name of the struct field, nested fields are dot separated
+	Field string
+	// Id is the id of the row value
+	Id  string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d: field %s (%s): %v", e.Row, e.Field, e.Id, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrors is every field of a row which could not be decoded
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any error in the list matches target
+func (e DecodeErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
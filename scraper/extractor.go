@@ -0,0 +1,517 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/robertkrimen/otto"
+	"golang.org/x/net/html"
+)
+
+// Extractor runs a schema against goquery selections and returns the structured data it describes
+type Extractor struct {
+	schema   *Schema
+	compiled map[*Schema]*compiledSchema
+	baseURL  *url.URL
+	// scriptTimeout is how long a schema script may run, 0 means no limit
+	scriptTimeout time.Duration
+	types         *TypeRegistry
+	// vm runs the scripts of an extraction run, it is set on the copy made by run
+	vm *scriptVM
+}
+
+// compiledSchema holds the compiled paths of a schema node
+type compiledSchema struct {
+	css       *compiledPath
+	key       *compiledPath
+	val       *compiledPath
+	next      *compiledPath
+	script    *otto.Script
+	scriptErr error
+}
+
+func compileSchema(schema *Schema) *compiledSchema {
+	c := &compiledSchema{css: compileSelector(schema.Selector, schema.CssPath)}
+	if schema.Type == PROPERTY_ARRAY {
+		c.key = compilePath(schema.KeyPath)
+		c.val = compilePath(schema.ValPath)
+	}
+	if len(schema.NextPath) > 0 && schema.NextPath[0] != "" {
+		c.next = compilePath(schema.NextPath)
+	}
+	if schema.Script != "" {
+		c.script, c.scriptErr = compileScript(schema.Script)
+	}
+	return c
+}
+
+// ExtractedRow is a row extracted by an Extractor along with the errors of its properties
+type ExtractedRow struct {
+	Index  int
+	Data   map[string]interface{}
+	Errors ExtractErrors
+	// Reasons are the reasons the row does not satisfy its schema, see Schema.ValidateRow
+	Reasons []string
+}
+
+// NewExtractor returns an extractor for schema, the selectors and regular expressions of the
+// schema are compiled once here. The schema must not be changed while the extractor is used
+func NewExtractor(schema *Schema) *Extractor {
+	e := &Extractor{schema: schema, compiled: map[*Schema]*compiledSchema{}, scriptTimeout: DefaultScriptTimeout}
+	if schema != nil {
+		e.compile(schema)
+	}
+	return e
+}
+
+func (e *Extractor) compile(schema *Schema) {
+	e.compiled[schema] = compileSchema(schema)
+	for i := range schema.Properties {
+		e.compile(&schema.Properties[i])
+	}
+}
+
+// WithBaseURL returns a copy of the extractor which resolves relative urls against base, the url
+// of the document. A <base> element in the document takes precedence
+func (e *Extractor) WithBaseURL(base *url.URL) *Extractor {
+	c := *e
+	c.baseURL = base
+	return &c
+}
+
+// WithTypes returns a copy of the extractor which looks up custom types in types, DefaultTypes is used if it is nil
+func (e *Extractor) WithTypes(types *TypeRegistry) *Extractor {
+	c := *e
+	c.types = types
+	return &c
+}
+
+// WithScriptTimeout returns a copy of the extractor whose schema scripts are interrupted after
+// timeout, 0 lets scripts run until they end
+func (e *Extractor) WithScriptTimeout(timeout time.Duration) *Extractor {
+	c := *e
+	c.scriptTimeout = timeout
+	return &c
+}
+
+// run returns a copy of the extractor with a script vm for an extraction run, the extractor itself
+// when it already is one
+func (e *Extractor) run() *Extractor {
+	if e.vm != nil {
+		return e
+	}
+	c := *e
+	c.vm = &scriptVM{}
+	return &c
+}
+
+// paths returns the compiled paths of schema, schemas which are not part of the extractor schema are compiled on demand
+func (e *Extractor) paths(schema *Schema) *compiledSchema {
+	if c, ok := e.compiled[schema]; ok {
+		return c
+	}
+	return compileSchema(schema)
+}
+
+// Extract finds every match of the schema css path in node and returns a row for each match
+func (e *Extractor) Extract(node *goquery.Selection) []map[string]interface{} {
+	rows := []map[string]interface{}{}
+	extracted, _ := e.ExtractRows(node)
+	for _, row := range extracted {
+		rows = append(rows, row.Data)
+	}
+	return rows
+}
+
+// ExtractRows is like Extract but also returns the errors found in each row. An error is
+// returned if the schema css path itself can not be used
+func (e *Extractor) ExtractRows(node *goquery.Selection) ([]*ExtractedRow, error) {
+	e = e.run()
+	if e.schema == nil {
+		return nil, ErrNoSchema
+	}
+	rowNodes, err := e.rowNodes(node, e.schema)
+	if err != nil {
+		return nil, err
+	}
+	rows := []*ExtractedRow{}
+	rowNodes.Each(func(i int, s *goquery.Selection) {
+		rows = append(rows, e.RowResult(s, e.schema, i))
+	})
+	return rows, nil
+}
+
+// rowNodes returns the nodes of node selected by schema with its selector kind, finding no node is
+// not an error
+func (e *Extractor) rowNodes(node *goquery.Selection, schema *Schema) (*goquery.Selection, error) {
+	nodes, err := e.find(node, schema, -1)
+	if err != nil && !errors.Is(err, ErrFieldNotFound) {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// Row extracts every property of schema from node into a single row
+func (e *Extractor) Row(node *goquery.Selection, schema *Schema) map[string]interface{} {
+	return e.RowResult(node, schema, 0).Data
+}
+
+// RowResult is like Row but also returns the errors of the properties and validates the row,
+// index is the row index reported in errors
+func (e *Extractor) RowResult(node *goquery.Selection, schema *Schema, index int) *ExtractedRow {
+	e = e.run()
+	data, errs := e.properties(node, schema, index)
+	if schema.Script != "" {
+		val, serrs := e.script(node, schema, data, data, index)
+		if row, ok := val.(map[string]interface{}); ok {
+			data = row
+		} else if len(serrs) == 0 {
+			serrs = ExtractErrors{{Id: schema.Id, CssPath: schema.CssPath, Row: index,
+				Err: fmt.Errorf("%w: the row script returned %T instead of an object", ErrScript, val)}}
+		}
+		errs = append(errs, serrs...)
+	}
+	return &ExtractedRow{Index: index, Data: data, Errors: errs, Reasons: schema.ValidateRow(data)}
+}
+
+// Property extracts the value of a single property from node, it is nil if the property could not be extracted
+func (e *Extractor) Property(parentNode *goquery.Selection, property *Schema) interface{} {
+	e = e.run()
+	val, _ := e.value(parentNode, property, 0)
+	return val
+}
+
+// PropertyResult is like Property but also returns the errors found while extracting the property
+func (e *Extractor) PropertyResult(parentNode *goquery.Selection, property *Schema, index int) (interface{}, ExtractErrors) {
+	return e.RowPropertyResult(parentNode, property, nil, index)
+}
+
+// RowPropertyResult is like PropertyResult, row is the part of the row already extracted which is
+// passed to the script of the property
+func (e *Extractor) RowPropertyResult(parentNode *goquery.Selection, property *Schema, row map[string]interface{}, index int) (interface{}, ExtractErrors) {
+	e = e.run()
+	val, errs := e.value(parentNode, property, index)
+	if len(errs) > 0 && val == nil {
+		return nil, errs
+	}
+	val, serrs := e.transform(parentNode, property, val, row, index)
+	return val, append(errs, serrs...)
+}
+
+// properties extracts the properties of schema from node, the fields of mergeWithParent properties
+// are merged into the returned row
+func (e *Extractor) properties(node *goquery.Selection, schema *Schema, index int) (map[string]interface{}, ExtractErrors) {
+	var (
+		data = make(map[string]interface{})
+		errs ExtractErrors
+	)
+	for i := range schema.Properties {
+		property := &schema.Properties[i]
+		val, perrs := e.RowPropertyResult(node, property, data, index)
+		errs = append(errs, perrs...)
+		if property.MergeWithParent {
+			// the fields of a merged object are part of the row, see Schema.ValidateRow
+			merge, _ := val.(map[string]interface{})
+			for k, v := range merge {
+				data[k] = v
+			}
+			continue
+		}
+		data[property.Id] = val
+	}
+	return data, errs
+}
+
+// find returns the nodes matched by the css path of property in node
+func (e *Extractor) find(node *goquery.Selection, property *Schema, index int) (*goquery.Selection, *ExtractError) {
+	css := e.paths(property).css
+	if err := css.err(); err != nil {
+		return node.Slice(0, 0), &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err}
+	}
+	var found *goquery.Selection
+	switch {
+	case css.xpath != nil:
+		var nodes []*html.Node
+		for _, n := range node.Nodes {
+			nodes = append(nodes, htmlquery.QuerySelectorAll(n, css.xpath)...)
+		}
+		// Slice(0, 0) shares its backing array with node, clip it so AddNodes can not overwrite node
+		found = node.Slice(0, 0)
+		found.Nodes = found.Nodes[:0:0]
+		found = found.AddNodes(nodes...)
+	case css.sel != nil:
+		found = node.FindMatcher(css.sel)
+	case selectsNodes(css.kind):
+		return node, nil
+	default:
+		return node.Slice(0, 0), &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index,
+			Err: fmt.Errorf("%w: a %s selector can not select nodes", ErrInvalidSelector, css.kind)}
+	}
+	if found.Length() == 0 {
+		return found, &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index, Err: ErrFieldNotFound}
+	}
+	return found, nil
+}
+
+func (e *Extractor) value(parentNode *goquery.Selection, property *Schema, index int) (interface{}, ExtractErrors) {
+	if parentNode == nil {
+		return nil, nil
+	}
+	switch property.Selector {
+	case JSONPATH_SELECTOR:
+		return e.jsonPathValue(parentNode, property, index)
+	case REGEX_SELECTOR:
+		return e.regexValue(parentNode, property, index)
+	}
+	propertyNode, ferr := e.find(parentNode, property, index)
+	if ferr != nil {
+		return nil, ExtractErrors{ferr}
+	}
+	paths := e.paths(property)
+	notFound := func(attr string) ExtractErrors {
+		return ExtractErrors{{Id: property.Id, CssPath: property.CssPath, Row: index,
+			Err: fmt.Errorf("%w: no attribute %s", ErrFieldNotFound, attr)}}
+	}
+	fail := func(err error) (interface{}, ExtractErrors) {
+		return nil, ExtractErrors{{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err}}
+	}
+	switch property.Type {
+	case OBJECT_PROPERTY:
+		data, errs := e.properties(propertyNode, property, index)
+		return e.transformResult(property, data, errs, index)
+	case LONGTEXT_PROPERTY:
+		if len(property.Transforms) > 0 {
+			val, ok, err := e.text(property, paths.css.re, propertyNode)
+			if !ok {
+				return nil, notFound(property.CssPath[1])
+			}
+			if err != nil {
+				return fail(err)
+			}
+			return val, nil
+		}
+		if len(property.CssPath) > 1 {
+			if val, ok := propertyNode.Attr(property.CssPath[1]); ok {
+				val = stringMinifier(val)
+				return removeInvalidUtf(val), nil
+			}
+			return nil, notFound(property.CssPath[1])
+		}
+		return removeInvalidUtf(propertyNode.Text()), nil
+	case STRING_PROPERTY:
+		val, ok, err := e.text(property, paths.css.re, propertyNode)
+		if !ok {
+			return nil, notFound(property.CssPath[1])
+		}
+		if err != nil {
+			return fail(err)
+		}
+		return val, nil
+	case IMAGE_PROPERTY:
+		val, ok, err := e.text(property, paths.css.re, propertyNode)
+		if !ok {
+			return nil, notFound(property.CssPath[1])
+		}
+		if err != nil {
+			return fail(err)
+		}
+		s, isString := val.(string)
+		if !isString {
+			return fail(fmt.Errorf("%w: %T is not a link", ErrInvalidValue, val))
+		}
+		link, err := resolveURL(documentBase(e.baseURL, propertyNode), s)
+		if err != nil {
+			return fail(err)
+		}
+		return link, nil
+	case URLLIST_PROPERTY:
+		var (
+			result []string
+			errs   ExtractErrors
+		)
+		base := documentBase(e.baseURL, propertyNode)
+		propertyNode.Each(func(i int, s *goquery.Selection) {
+			val, ok, err := e.text(property, paths.css.re, s)
+			if !ok {
+				return
+			}
+			if err != nil {
+				errs = append(errs, &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err})
+				return
+			}
+			var links []string
+			switch v := val.(type) {
+			case string:
+				links = []string{v}
+			case []string:
+				links = v
+			}
+			for _, val := range links {
+				if val == "" {
+					continue
+				}
+				link, err := resolveURL(base, val)
+				if err != nil {
+					errs = append(errs, &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err})
+					continue
+				}
+				result = append(result, link)
+			}
+		})
+		return result, errs
+	case ARRAY_PROPERTY:
+		var items []interface{}
+		if len(property.CssPath) > 1 {
+			propertyNode.Each(func(i int, s *goquery.Selection) {
+				if val, ok := s.Attr(property.CssPath[1]); ok {
+					items = append(items, val)
+				}
+			})
+		} else {
+			propertyNode.Each(func(i int, s *goquery.Selection) {
+				items = append(items, s.Text())
+			})
+		}
+		if len(property.Transforms) > 0 {
+			val, err := applyTransforms(property.Transforms, items)
+			if err != nil {
+				return fail(err)
+			}
+			return val, nil
+		}
+		var result []string
+		for _, item := range items {
+			result = append(result, cleanText(item.(string)))
+		}
+		return result, nil
+	case INT_PROPERTY, FLOAT_PROPERTY, DECIMAL_PROPERTY, BOOL_PROPERTY, DATETIME_PROPERTY, CURRENCY_PROPERTY, URL_PROPERTY:
+		raw, ok, err := e.text(property, paths.css.re, propertyNode)
+		if !ok {
+			return nil, notFound(property.CssPath[1])
+		}
+		if err != nil {
+			return fail(err)
+		}
+		val, err := parseValue(property.Type, property.valueFormat(), raw, documentBase(e.baseURL, propertyNode))
+		if err != nil {
+			return fail(err)
+		}
+		return val, nil
+	case JSONLD_PROPERTY, MICRODATA_PROPERTY, OPENGRAPH_PROPERTY, STRUCTURED_PROPERTY:
+		val, errs := e.structuredValue(propertyNode, property, index)
+		return e.transformResult(property, val, errs, index)
+	case PROPERTY_ARRAY:
+		if err := paths.key.err(); err != nil {
+			return nil, ExtractErrors{{Id: property.Id, CssPath: property.KeyPath, Row: index, Err: err}}
+		}
+		if err := paths.val.err(); err != nil {
+			return nil, ExtractErrors{{Id: property.Id, CssPath: property.ValPath, Row: index, Err: err}}
+		}
+		return e.propertyArray(propertyNode, property, paths, index)
+	default:
+		//check type formatters
+		if customType, ok := typesOrDefault(e.types).Lookup(property.Type); ok {
+			val, err := customType(property, propertyNode)
+			if err != nil {
+				return fail(err)
+			}
+			return e.transformResult(property, val, nil, index)
+		}
+		val, ok, err := e.text(property, paths.css.re, propertyNode)
+		if !ok {
+			return nil, notFound(property.CssPath[1])
+		}
+		if err != nil {
+			return fail(err)
+		}
+		return val, nil
+	}
+}
+
+// text returns the css[1] attribute of node or its text with the css[2] regex applied. The
+// whitespace and utf8 cleanup is replaced by the transforms of the property when it has some
+func (e *Extractor) text(property *Schema, re *regexp.Regexp, node *goquery.Selection) (interface{}, bool, error) {
+	if len(property.Transforms) == 0 {
+		val, ok := stringValFromPath(property.CssPath, re, node)
+		return val, ok, nil
+	}
+	raw, ok := rawValFromPath(property.CssPath, re, node)
+	if !ok {
+		return nil, false, nil
+	}
+	val, err := applyTransforms(property.Transforms, raw)
+	return val, true, err
+}
+
+// transformResult applies the transforms of property to val, a value which is not text
+func (e *Extractor) transformResult(property *Schema, val interface{}, errs ExtractErrors, index int) (interface{}, ExtractErrors) {
+	if len(property.Transforms) == 0 {
+		return val, errs
+	}
+	val, err := applyTransforms(property.Transforms, val)
+	if err != nil {
+		return nil, append(errs, &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err})
+	}
+	return val, errs
+}
+
+// propertyArray retrieves all items with the same css path then gets the key-value pair
+// of each as specified in the property definition. Keys go through the key transforms of the
+// property and values through its transforms
+func (e *Extractor) propertyArray(propertyNode *goquery.Selection, property *Schema, paths *compiledSchema, index int) (interface{}, ExtractErrors) {
+	var errs ExtractErrors
+	keyTransforms := property.KeyTransforms
+	if len(keyTransforms) == 0 {
+		keyTransforms = defaultKeyTransforms
+	}
+	props := map[string]interface{}{}
+	propertyNode.Each(func(i int, s *goquery.Selection) {
+		var (
+			raw string
+			ok  bool
+		)
+		if paths.key.sel == nil {
+			raw, ok = rawValFromPath(property.KeyPath, paths.key.re, s.Clone().Children().Remove().End())
+		} else {
+			raw, ok = rawValFromPath(property.KeyPath, paths.key.re, s.FindMatcher(paths.key.sel).First())
+		}
+		if !ok {
+			return
+		}
+		k, err := applyTransforms(keyTransforms, raw)
+		if err != nil {
+			errs = append(errs, &ExtractError{Id: property.Id, CssPath: property.KeyPath, Row: index, Err: err})
+			return
+		}
+		key, _ := k.(string)
+		if len(key) == 0 {
+			return
+		}
+		valNode := s
+		if paths.val.sel != nil {
+			valNode = s.FindMatcher(paths.val.sel)
+		}
+		valProperty := &Schema{CssPath: property.ValPath, Transforms: property.Transforms}
+		val, ok, err := e.text(valProperty, paths.val.re, valNode.First())
+		if !ok {
+			return
+		}
+		if err != nil {
+			errs = append(errs, &ExtractError{Id: property.Id, CssPath: property.ValPath, Row: index, Err: err})
+			return
+		}
+		if str, isString := val.(string); isString && (str == "" || strings.ToLower(str) == "nil") {
+			return
+		}
+		if val != nil {
+			props[key] = val
+		}
+	})
+	return props, errs
+}
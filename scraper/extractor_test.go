@@ -0,0 +1,322 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var productPage = `<html>
+<body>
+<div class="product">
+<h2><a href="/p/1">Blue Shoe</a></h2>
+<span class="price">42</span>
+<img src="/img/1.png"/>
+<ul class="tags"><li>shoes</li><li>blue</li></ul>
+<dl>
+<dt><b>Size:</b> <i>10</i></dt>
+<dt><b>Colour Name:</b> <i>Blue</i></dt>
+</dl>
+</div>
+<div class="product">
+<h2><a href="/p/2">Red Hat</a></h2>
+<span class="price">7</span>
+</div>
+</body>
+</html>`
+
+func productDoc() *goquery.Document {
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(productPage))
+	return doc
+}
+
+func TestExtractor_Property(t *testing.T) {
+	node := productDoc().Find(".product").First()
+	tests := []struct {
+		name     string
+		property *Schema
+		want     interface{}
+	}{
+		{
+			name:     "Test string",
+			property: &Schema{Id: "name", Type: STRING_PROPERTY, CssPath: []string{"h2 a"}},
+			want:     "Blue Shoe",
+		},
+		{
+			name:     "Test string attribute",
+			property: &Schema{Id: "link", Type: STRING_PROPERTY, CssPath: []string{"h2 a", "href"}},
+			want:     "/p/1",
+		},
+		{
+			name:     "Test missing attribute",
+			property: &Schema{Id: "link", Type: STRING_PROPERTY, CssPath: []string{"h2 a", "title"}},
+			want:     nil,
+		},
+		{
+			name:     "Test integer",
+			property: &Schema{Id: "price", Type: INT_PROPERTY, CssPath: []string{".price"}},
+			want:     42,
+		},
+		{
+			name:     "Test image",
+			property: &Schema{Id: "image", Type: IMAGE_PROPERTY, CssPath: []string{"img", "src"}},
+			want:     "/img/1.png",
+		},
+		{
+			name:     "Test array",
+			property: &Schema{Id: "tags", Type: ARRAY_PROPERTY, CssPath: []string{".tags li"}},
+			want:     []string{"shoes", "blue"},
+		},
+		{
+			name: "Test object",
+			property: &Schema{Id: "title", Type: OBJECT_PROPERTY, CssPath: []string{"h2"}, Properties: []Schema{
+				{Id: "text", Type: STRING_PROPERTY, CssPath: []string{"a"}},
+			}},
+			want: map[string]interface{}{"text": "Blue Shoe"},
+		},
+		{
+			name: "Test property array",
+			property: &Schema{Id: "specs", Type: PROPERTY_ARRAY, CssPath: []string{"dt"},
+				KeyPath: []string{"b"}, ValPath: []string{"i"}},
+			want: map[string]interface{}{"size": "10", "colour_name": "Blue"},
+		},
+		{
+			name:     "Test default type",
+			property: &Schema{Id: "name", CssPath: []string{"h2"}},
+			want:     "Blue Shoe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewExtractor(nil).Property(node, tt.property); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extractor.Property() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractor_Extract(t *testing.T) {
+	schema := SchemaFromString(`{
+		"css": [".product"],
+		"properties": [
+			{"id": "name", "type": "string", "css": ["h2 a"]},
+			{"id": "price", "type": "integer", "css": [".price"]}
+		]
+	}`)
+	want := []map[string]interface{}{
+		{"name": "Blue Shoe", "price": 42},
+		{"name": "Red Hat", "price": 7},
+	}
+	if got := NewExtractor(schema).Extract(productDoc().Selection); !reflect.DeepEqual(got, want) {
+		t.Errorf("Extractor.Extract() = %#v, want %#v", got, want)
+	}
+}
+
+func TestExtractor_ExtractRowsMergeWithParent(t *testing.T) {
+	schema := SchemaFromString(`{
+		"css": [".product"],
+		"properties": [
+			{"id": "name", "type": "string", "css": ["h2 a"], "required": true},
+			{"type": "object", "css": [".price"], "mergeWithParent": true, "properties": [
+				{"id": "price", "type": "integer", "css": [""], "required": true}
+			]}
+		]
+	}`)
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("Schema.Validate() error = %v", err)
+	}
+	rows, err := NewExtractor(schema).ExtractRows(productDoc().Selection)
+	if err != nil {
+		t.Fatalf("Extractor.ExtractRows() error = %v", err)
+	}
+	want := []map[string]interface{}{
+		{"name": "Blue Shoe", "price": 42},
+		{"name": "Red Hat", "price": 7},
+	}
+	for i, row := range rows {
+		if !reflect.DeepEqual(row.Data, want[i]) {
+			t.Errorf("ExtractedRow.Data = %#v, want %#v", row.Data, want[i])
+		}
+		if len(row.Reasons) > 0 {
+			t.Errorf("ExtractedRow.Reasons = %v, want none", row.Reasons)
+		}
+	}
+}
+
+func TestExtractor_ExtractRowsErrors(t *testing.T) {
+	schema := SchemaFromString(`{
+		"css": [".product"],
+		"properties": [
+			{"id": "name", "type": "string", "css": ["h2 a"]},
+			{"id": "image", "type": "imageurl", "css": ["img", "src"]},
+			{"id": "price", "type": "string", "css": [".price", "data-x", "(\\d+"]},
+			{"id": "broken", "type": "string", "css": ["div[[["]},
+			{"id": "empty", "type": "string", "css": []}
+		]
+	}`)
+	rows, err := NewExtractor(schema).ExtractRows(productDoc().Selection)
+	if err != nil {
+		t.Fatalf("Extractor.ExtractRows() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Extractor.ExtractRows() returned %d rows, want 2", len(rows))
+	}
+	type wantErr struct {
+		id  string
+		row int
+		err error
+	}
+	tests := []struct {
+		name string
+		row  *ExtractedRow
+		want []wantErr
+	}{
+		{
+			name: "Test first row",
+			row:  rows[0],
+			want: []wantErr{
+				{"price", 0, ErrInvalidSelector},
+				{"broken", 0, ErrInvalidSelector},
+				{"empty", 0, ErrInvalidSelector},
+			},
+		},
+		{
+			name: "Test row with absent field",
+			row:  rows[1],
+			want: []wantErr{
+				{"image", 1, ErrFieldNotFound},
+				{"price", 1, ErrInvalidSelector},
+				{"broken", 1, ErrInvalidSelector},
+				{"empty", 1, ErrInvalidSelector},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.row.Errors) != len(tt.want) {
+				t.Fatalf("ExtractedRow.Errors = %v, want %d errors", tt.row.Errors, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := tt.row.Errors[i]
+				if got.Id != want.id || got.Row != want.row || !errors.Is(got, want.err) {
+					t.Errorf("ExtractedRow.Errors[%d] = %v, want %s row %d %v", i, got, want.id, want.row, want.err)
+				}
+			}
+			if tt.row.Data["name"] == nil {
+				t.Errorf("ExtractedRow.Data = %v, want a name", tt.row.Data)
+			}
+		})
+	}
+}
+
+func TestPageScraper_GetRowsInvalidSelector(t *testing.T) {
+	p := NewPageScraper(client, SchemaFromString(`{
+		"css": [".product"],
+		"properties": [{"id": "broken", "type": "string", "css": ["div[[["]}]
+	}`))
+	_, err := p.GetRows([]byte(productPage))
+	if !errors.Is(err, ErrInvalidSelector) {
+		t.Errorf("PageScraper.GetRows() error = %v, want %v", err, ErrInvalidSelector)
+	}
+}
+
+func TestSchema_ValidateRow(t *testing.T) {
+	schema := SchemaFromString(`{
+		"css": [".product"],
+		"properties": [
+			{"id": "name", "type": "string", "css": ["h2 a"], "required": true},
+			{"id": "image", "type": "imageurl", "css": ["img", "src"], "default": "/img/none.png"},
+			{"id": "alt", "type": "string", "css": ["img", "alt"], "required": true, "nullable": true},
+			{"id": "meta", "type": "object", "css": [""], "properties": [
+				{"id": "price", "type": "integer", "css": [".price"], "required": true}
+			]}
+		]
+	}`)
+	tests := []struct {
+		name        string
+		row         map[string]interface{}
+		want        map[string]interface{}
+		wantReasons []string
+	}{
+		{
+			name: "Test valid row with default",
+			row:  map[string]interface{}{"name": "Red Hat", "image": nil, "alt": nil, "meta": map[string]interface{}{"price": 7}},
+			want: map[string]interface{}{"name": "Red Hat", "image": "/img/none.png", "alt": nil, "meta": map[string]interface{}{"price": 7}},
+		},
+		{
+			name:        "Test null required field",
+			row:         map[string]interface{}{"name": nil, "alt": nil, "meta": map[string]interface{}{"price": 7}},
+			want:        map[string]interface{}{"name": nil, "image": "/img/none.png", "alt": nil, "meta": map[string]interface{}{"price": 7}},
+			wantReasons: []string{"name: required field is null"},
+		},
+		{
+			name:        "Test missing required fields",
+			row:         map[string]interface{}{"name": "Red Hat", "meta": map[string]interface{}{}},
+			want:        map[string]interface{}{"name": "Red Hat", "image": "/img/none.png", "meta": map[string]interface{}{}},
+			wantReasons: []string{"alt: required field is missing", "meta.price: required field is missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons := schema.ValidateRow(tt.row)
+			if !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Errorf("Schema.ValidateRow() = %v, want %v", reasons, tt.wantReasons)
+			}
+			if !reflect.DeepEqual(tt.row, tt.want) {
+				t.Errorf("Schema.ValidateRow() row = %v, want %v", tt.row, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageScraper_GetRowsRejected(t *testing.T) {
+	rejected := make(chan *RejectedRow, 2)
+	p := NewPageScraper(client, SchemaFromString(`{
+		"css": [".product"],
+		"properties": [
+			{"id": "name", "type": "string", "css": ["h2 a"]},
+			{"id": "image", "type": "imageurl", "css": ["img", "src"], "required": true}
+		]
+	}`), RejectRowsTo(rejected))
+	rows, err := p.GetRows([]byte(productPage))
+	if err != nil {
+		t.Fatalf("PageScraper.GetRows() error = %v", err)
+	}
+	want := []interface{}{map[string]interface{}{"name": "Blue Shoe", "image": "/img/1.png"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("PageScraper.GetRows() = %v, want %v", rows, want)
+	}
+	close(rejected)
+	var got []*RejectedRow
+	for row := range rejected {
+		got = append(got, row)
+	}
+	if len(got) != 1 || got[0].Index != 1 || got[0].Data["name"] != "Red Hat" {
+		t.Errorf("PageScraper.GetRows() rejected = %v, want the second row", got)
+	}
+}
+
+func TestPageScraper_GetRowsRejectedBlocked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(productPage))
+	}))
+	defer server.Close()
+	// nothing reads the rejected rows
+	p := NewPageScraper(client, SchemaFromString(`{
+		"css": [".product"],
+		"properties": [{"id": "image", "type": "imageurl", "css": ["img", "src"], "required": true}]
+	}`), RejectRowsTo(make(chan *RejectedRow)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, summary := collect(NewRunner(p, server.URL).RunContext(ctx))
+	if !errors.Is(summary.Err, context.DeadlineExceeded) {
+		t.Errorf("Runner.RunContext() error = %v, want %v", summary.Err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+)
+
+// DefaultUserAgent is the User-Agent of the requests of a DefaultClient without UserAgents or a
+// User-Agent header
+const DefaultUserAgent = `Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/39.0.2171.27 Safari/537.36`
+
+// UserAgentRotator picks the User-Agent of each request, it must be safe for concurrent use
+type UserAgentRotator interface {
+	UserAgent() string
+}
+
+// StaticUserAgent is a UserAgentRotator which always uses the same user agent
+type StaticUserAgent string
+
+func (s StaticUserAgent) UserAgent() string {
+	return string(s)
+}
+
+type roundRobinUserAgents struct {
+	agents []string
+	next   uint64
+}
+
+// RoundRobinUserAgents returns a UserAgentRotator which uses agents in turn
+func RoundRobinUserAgents(agents ...string) UserAgentRotator {
+	return &roundRobinUserAgents{agents: agents}
+}
+
+func (r *roundRobinUserAgents) UserAgent() string {
+	if len(r.agents) == 0 {
+		return ""
+	}
+	return r.agents[(atomic.AddUint64(&r.next, 1)-1)%uint64(len(r.agents))]
+}
+
+type randomUserAgents []string
+
+// RandomUserAgents returns a UserAgentRotator which picks one of agents at random for each request
+func RandomUserAgents(agents ...string) UserAgentRotator {
+	return randomUserAgents(agents)
+}
+
+func (r randomUserAgents) UserAgent() string {
+	if len(r) == 0 {
+		return ""
+	}
+	return r[rand.Intn(len(r))]
+}
+
+type headerKey struct{}
+
+// WithHeader returns a context which makes the requests of a DefaultClient send header, its values
+// replace the default headers and user agent of the client
+func WithHeader(ctx context.Context, header http.Header) context.Context {
+	if existing, ok := ctx.Value(headerKey{}).(http.Header); ok {
+		merged := existing.Clone()
+		copyHeader(merged, header)
+		header = merged
+	}
+	return context.WithValue(ctx, headerKey{}, header)
+}
+
+// setHeaders sets the headers of req, the default headers of the client come first, then the user
+// agent and the headers of the context of req
+func (c *DefaultClient) setHeaders(req *http.Request) {
+	copyHeader(req.Header, c.Header)
+	ua := ""
+	if c.UserAgents != nil {
+		ua = c.UserAgents.UserAgent()
+	}
+	switch {
+	case ua != "":
+		req.Header.Set("User-Agent", ua)
+	case req.Header.Get("User-Agent") == "":
+		req.Header.Set("User-Agent", DefaultUserAgent)
+	}
+	if header, ok := req.Context().Value(headerKey{}).(http.Header); ok {
+		copyHeader(req.Header, header)
+	}
+}
+
+// copyHeader replaces the values of dst by the values of src for every key of src
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		dst.Del(key)
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUserAgentRotator(t *testing.T) {
+	Convey("round robin user agents are used in turn", t, func() {
+		agents := RoundRobinUserAgents("a", "b", "c")
+		var picked []string
+		for i := 0; i < 4; i++ {
+			picked = append(picked, agents.UserAgent())
+		}
+		So(picked, ShouldResemble, []string{"a", "b", "c", "a"})
+	})
+	Convey("random user agents are picked from the list", t, func() {
+		agents := RandomUserAgents("a", "b")
+		for i := 0; i < 10; i++ {
+			So(agents.UserAgent(), ShouldBeIn, "a", "b")
+		}
+		So(RandomUserAgents().UserAgent(), ShouldEqual, "")
+	})
+	Convey("a static user agent is always the same", t, func() {
+		So(StaticUserAgent("bot").UserAgent(), ShouldEqual, "bot")
+	})
+}
+
+func TestDefaultClient_Header(t *testing.T) {
+	Convey("given a server which records the request headers", t, func() {
+		var (
+			mu      sync.Mutex
+			headers []http.Header
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			headers = append(headers, r.Header)
+			mu.Unlock()
+			w.Write([]byte("<html></html>"))
+		}))
+		defer server.Close()
+		call := func(client Client, ctx context.Context) {
+			resp, err := client.GetContext(ctx, server.URL)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			resp, err = client.PostContext(ctx, server.URL, url.Values{"q": {"lamp"}})
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			_, err = client.GetBytesContext(ctx, server.URL)
+			So(err, ShouldBeNil)
+			_, err = client.PostBytesContext(ctx, server.URL, url.Values{"q": {"lamp"}})
+			So(err, ShouldBeNil)
+			_, err = client.GetDocContext(ctx, server.URL)
+			So(err, ShouldBeNil)
+		}
+		Convey("every method sends the default user agent", func() {
+			client, _ := NewDefaultClient(nil)
+			call(client, context.Background())
+			So(headers, ShouldHaveLength, 5)
+			for _, h := range headers {
+				So(h.Get("User-Agent"), ShouldEqual, DefaultUserAgent)
+			}
+			So(headers[1].Get("Content-Type"), ShouldEqual, "application/x-www-form-urlencoded")
+		})
+		Convey("every method sends the client headers and a rotated user agent", func() {
+			client, _ := NewDefaultClient(&DefaultClient{
+				Header:     http.Header{"Accept-Language": {"en"}, "User-Agent": {"ignored"}},
+				UserAgents: RoundRobinUserAgents("a", "b"),
+			})
+			call(client, context.Background())
+			var agents []string
+			for _, h := range headers {
+				So(h.Get("Accept-Language"), ShouldEqual, "en")
+				agents = append(agents, h.Get("User-Agent"))
+			}
+			So(agents, ShouldResemble, []string{"a", "b", "a", "b", "a"})
+		})
+		Convey("the user agent of the client headers is used without a rotator", func() {
+			client, _ := NewDefaultClient(&DefaultClient{Header: http.Header{"user-agent": {"bot"}}})
+			call(client, context.Background())
+			So(headers[2].Get("User-Agent"), ShouldEqual, "bot")
+		})
+		Convey("the headers of the context override the client headers", func() {
+			client, _ := NewDefaultClient(&DefaultClient{Header: http.Header{"Accept-Language": {"en"}}})
+			ctx := WithHeader(context.Background(), http.Header{"Accept-Language": {"fr"}})
+			ctx = WithHeader(ctx, http.Header{"User-Agent": {"custom"}})
+			call(client, ctx)
+			for _, h := range headers {
+				So(h.Get("Accept-Language"), ShouldEqual, "fr")
+				So(h.Get("User-Agent"), ShouldEqual, "custom")
+			}
+		})
+	})
+}
@@ -0,0 +1,113 @@
+package scraper
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pagesTransport serves the pages of a fake site, unknown urls are not found
+type pagesTransport map[string]string
+
+func (p pagesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	page, ok := p[req.URL.String()]
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusNotFound
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       ioutil.NopCloser(strings.NewReader(page)),
+		Request:    req,
+	}, nil
+}
+
+func pagesClient(pages map[string]string) Client {
+	c, _ := NewDefaultClient(&DefaultClient{RetryPolicy: NoRetry})
+	c.(*DefaultClient).Client.Transport = pagesTransport(pages)
+	return c
+}
+
+var shopPages = map[string]string{
+	"http://shop.test/list": `<ul>
+		<li><a href="/p/1">Lamp</a></li>
+		<li><a href="/p/2">Chair</a></li>
+	</ul>`,
+	"http://shop.test/p/1": `<div class="product"><h1>Lamp</h1><span class="price">12</span></div>`,
+	"http://shop.test/p/2": `<div class="product"><h1>Chair</h1></div>`,
+}
+
+func TestJob_ScrapeStreamContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   []map[string]interface{}
+	}{
+		{
+			name: "Test urllist",
+			schema: `{
+				"type": "urllist",
+				"css": ["li a", "href", "^(/p/\\d+)"],
+				"properties": [{
+					"id": "product",
+					"css": [".product"],
+					"properties": [
+						{"id": "name", "type": "string", "css": ["h1"]},
+						{"type": "object", "css": [".price"], "mergeWithParent": true, "properties": [
+							{"id": "price", "type": "integer", "css": [""], "required": true}
+						]}
+					]
+				}]
+			}`,
+			want: []map[string]interface{}{{"name": "Lamp", "price": 12}},
+		},
+		{
+			name: "Test urllist with xpath selectors",
+			schema: `{
+				"type": "urllist",
+				"selector": "xpath",
+				"css": ["//li/a", "href"],
+				"properties": [{
+					"id": "product",
+					"selector": "xpath",
+					"css": ["//div[@class='product']"],
+					"properties": [
+						{"id": "name", "type": "string", "css": ["h1"]},
+						{"id": "price", "type": "integer", "css": [".price"], "required": true}
+					]
+				}]
+			}`,
+			want: []map[string]interface{}{{"name": "Lamp", "price": 12}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected := make(chan *RejectedRow, 10)
+			job := &Job{
+				URL:       "http://shop.test/list",
+				JobSchema: SchemaFromString(tt.schema),
+				Con:       pagesClient(shopPages),
+				Rejected:  rejected,
+			}
+			rows, err := job.ScrapeStreamContext(context.Background())
+			if err != nil {
+				t.Fatalf("Job.ScrapeStreamContext() error = %v", err)
+			}
+			got := []map[string]interface{}{}
+			for row := range rows {
+				got = append(got, row)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Job.ScrapeStreamContext() = %v, want %v", got, tt.want)
+			}
+			if len(rejected) != 1 {
+				t.Errorf("Job.ScrapeStreamContext() rejected %d rows, want 1", len(rejected))
+			}
+		})
+	}
+}
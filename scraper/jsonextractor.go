@@ -0,0 +1,213 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+	. "github.com/cstockton/go-conv"
+)
+
+// JSONExtractor runs a ParserSchema against JSONData and returns the rows it describes
+type JSONExtractor struct {
+	schema *ParserSchema
+}
+
+// NewJSONExtractor returns an extractor for schema, parsers registered on schema are used for
+// fields of every level
+func NewJSONExtractor(schema *ParserSchema) *JSONExtractor {
+	return &JSONExtractor{schema: schema}
+}
+
+// ExtractRows finds the rows at the schema path of data and extracts the schema fields from each.
+// The path can hold an array of rows or a single row. If the schema has no fields each row is
+// returned as is
+func (e *JSONExtractor) ExtractRows(data *JSONData) ([]*ExtractedRow, error) {
+	if e.schema == nil {
+		return nil, ErrNoSchema
+	}
+	if data == nil || data.data == nil {
+		return nil, ErrNoData
+	}
+	node := searchJSON(data.data, e.schema.Path)
+	if node == nil {
+		return nil, ErrNoData
+	}
+	var items []interface{}
+	if v, ok := node.Data().([]interface{}); ok {
+		items = v
+	} else {
+		items = []interface{}{node.Data()}
+	}
+	if e.schema.Limit > 0 && len(items) > e.schema.Limit {
+		items = items[:e.schema.Limit]
+	}
+	rows := []*ExtractedRow{}
+	for i, item := range items {
+		row := &ExtractedRow{Index: i}
+		if len(e.schema.Fields) == 0 {
+			row.Data, _ = item.(map[string]interface{})
+		} else {
+			row.Data, row.Errors = e.fields(item, e.schema, i)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Rows is like ExtractRows but only returns the data of each row
+func (e *JSONExtractor) Rows(data *JSONData) ([]interface{}, error) {
+	extracted, err := e.ExtractRows(data)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]interface{}, len(extracted))
+	for i, row := range extracted {
+		rows[i] = row.Data
+	}
+	return rows, nil
+}
+
+// Field extracts a single field from a json value, it is nil if the field could not be extracted
+func (e *JSONExtractor) Field(node interface{}, field *ParserSchema) interface{} {
+	val, _ := e.value(node, field, 0)
+	return val
+}
+
+func (e *JSONExtractor) fields(node interface{}, schema *ParserSchema, index int) (map[string]interface{}, ExtractErrors) {
+	var (
+		data = make(map[string]interface{})
+		errs ExtractErrors
+	)
+	for i := range schema.Fields {
+		field := &schema.Fields[i]
+		val, ferrs := e.value(node, field, index)
+		errs = append(errs, ferrs...)
+		if field.Type == KV_PROPERTY {
+			if kv, ok := val.(map[string]interface{}); ok {
+				for k, v := range kv {
+					data[k] = v
+				}
+			}
+			continue
+		}
+		data[field.Id] = val
+	}
+	return data, errs
+}
+
+// searchJSON returns the value at path in node, each path element can be a dot separated path
+func searchJSON(node *gabs.Container, path []string) *gabs.Container {
+	p := strings.Join(path, ".")
+	if p == "" {
+		return node
+	}
+	return node.Path(p)
+}
+
+// parserSchemaTypes are the built in types JSONExtractor.value can extract, the html only types of
+// Schema are not among them
+var parserSchemaTypes = []string{
+	ARRAY_PROPERTY, BOOL_PROPERTY, CURRENCY_PROPERTY, DATETIME_PROPERTY, DECIMAL_PROPERTY, FLOAT_PROPERTY,
+	IMAGE_PROPERTY, INT_PROPERTY, KV_PROPERTY, LONGTEXT_PROPERTY, OBJECT_PROPERTY, PROPERTY_ARRAY,
+	STRING_PROPERTY, URL_PROPERTY, URLLIST_PROPERTY,
+}
+
+func (e *JSONExtractor) value(node interface{}, field *ParserSchema, index int) (interface{}, ExtractErrors) {
+	fail := func(err error) (interface{}, ExtractErrors) {
+		return nil, ExtractErrors{{Id: field.Id, CssPath: field.Path, Row: index, Err: err}}
+	}
+	found := searchJSON(wrapJSON(node), field.Path)
+	if found == nil {
+		return fail(ErrFieldNotFound)
+	}
+	val := found.Data()
+	switch field.Type {
+	case "":
+		return val, nil
+	case STRING_PROPERTY, LONGTEXT_PROPERTY, IMAGE_PROPERTY:
+		if val == nil {
+			return nil, nil
+		}
+		s, err := String(val)
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", ErrInvalidValue, err))
+		}
+		return s, nil
+	case INT_PROPERTY, FLOAT_PROPERTY, DECIMAL_PROPERTY, BOOL_PROPERTY, DATETIME_PROPERTY, CURRENCY_PROPERTY, URL_PROPERTY:
+		if val == nil {
+			return nil, nil
+		}
+		v, err := parseValue(field.Type, field.valueFormat(), val, nil)
+		if err != nil {
+			return fail(err)
+		}
+		return v, nil
+	case ARRAY_PROPERTY, URLLIST_PROPERTY:
+		items, ok := val.([]interface{})
+		if !ok {
+			items = []interface{}{val}
+		}
+		if field.Limit > 0 && len(items) > field.Limit {
+			items = items[:field.Limit]
+		}
+		if len(field.Fields) == 0 {
+			return items, nil
+		}
+		var errs ExtractErrors
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			var ferrs ExtractErrors
+			result[i], ferrs = e.fields(item, field, index)
+			errs = append(errs, ferrs...)
+		}
+		return result, errs
+	case OBJECT_PROPERTY:
+		if len(field.Fields) == 0 {
+			return val, nil
+		}
+		if _, ok := val.(map[string]interface{}); !ok {
+			return fail(fmt.Errorf("%w: %T is not an object", ErrInvalidValue, val))
+		}
+		return e.fields(val, field, index)
+	case KV_PROPERTY:
+		kv, ok := val.(map[string]interface{})
+		if !ok {
+			return fail(fmt.Errorf("%w: %T is not an object", ErrInvalidValue, val))
+		}
+		return kv, nil
+	case PROPERTY_ARRAY:
+		items, ok := val.([]interface{})
+		if !ok {
+			return fail(fmt.Errorf("%w: %T is not an array", ErrInvalidValue, val))
+		}
+		props := map[string]interface{}{}
+		for _, item := range items {
+			key := searchJSON(wrapJSON(item), field.Key)
+			v := searchJSON(wrapJSON(item), field.Val)
+			if key == nil || v == nil || key.Data() == nil {
+				continue
+			}
+			k, err := String(key.Data())
+			if err != nil || k == "" {
+				continue
+			}
+			props[k] = v.Data()
+		}
+		return props, nil
+	default:
+		if parser, ok := e.schema.parsers[field.Type]; ok {
+			parsed, err := parser(val)
+			if err != nil {
+				return fail(fmt.Errorf("%w: %v", ErrInvalidValue, err))
+			}
+			return parsed, nil
+		}
+		return fail(fmt.Errorf("%w %q", ErrUnknownType, field.Type))
+	}
+}
+
+func wrapJSON(v interface{}) *gabs.Container {
+	c, _ := gabs.Consume(v)
+	return c
+}
@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var productsJSON = []byte(`{
+	"total": 2,
+	"data": {
+		"items": [
+			{
+				"name": "Blue Kettle",
+				"price": "1500",
+				"tags": ["kitchen", "blue"],
+				"seller": {"name": "Acme", "rating": 4},
+				"meta": {"sku": "k-1", "color": "blue"},
+				"specs": [{"label": "Weight", "value": "1kg"}, {"label": "Volume", "value": "2l"}]
+			},
+			{
+				"name": "Red Kettle",
+				"price": "cheap",
+				"tags": "kitchen",
+				"seller": {"name": "Acme", "rating": 3},
+				"meta": {"sku": "k-2"},
+				"specs": []
+			}
+		]
+	}
+}`)
+
+const productsSchema = `{
+	"path": ["data.items"],
+	"fields": [
+		{"id": "title", "path": ["name"], "type": "string"},
+		{"id": "price", "path": ["price"], "type": "integer"},
+		{"id": "tags", "path": ["tags"], "type": "array"},
+		{"id": "seller", "path": ["seller"], "type": "object", "fields": [
+			{"id": "name", "path": ["name"], "type": "string"}
+		]},
+		{"id": "meta", "path": ["meta"], "type": "kv"},
+		{"id": "specs", "path": ["specs"], "type": "property_array", "key": ["label"], "val": ["value"]},
+		{"id": "name", "path": ["name"], "type": "upper"}
+	]
+}`
+
+func TestJSONExtractor_ExtractRows(t *testing.T) {
+	Convey("given a parser schema with a custom parser", t, func() {
+		schema := NewParserSchemaFromString(productsSchema, Parser("upper", func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New("not a string")
+			}
+			return strings.ToUpper(s), nil
+		}))
+		So(schema, ShouldNotBeNil)
+		ex := NewJSONExtractor(schema)
+		Convey("extracting rows should apply every field", func() {
+			rows, err := ex.ExtractRows(NewJSONData(productsJSON))
+			So(err, ShouldBeNil)
+			So(len(rows), ShouldEqual, 2)
+			So(rows[0].Errors, ShouldBeEmpty)
+			So(rows[0].Data, ShouldResemble, map[string]interface{}{
+				"title":  "Blue Kettle",
+				"price":  1500,
+				"tags":   []interface{}{"kitchen", "blue"},
+				"seller": map[string]interface{}{"name": "Acme"},
+				"sku":    "k-1",
+				"color":  "blue",
+				"specs":  map[string]interface{}{"Weight": "1kg", "Volume": "2l"},
+				"name":   "BLUE KETTLE",
+			})
+			So(rows[1].Data["tags"], ShouldResemble, []interface{}{"kitchen"})
+			So(rows[1].Data["price"], ShouldBeNil)
+			So(errors.Is(rows[1].Errors, ErrInvalidValue), ShouldBeTrue)
+			So(rows[1].Errors[0].Id, ShouldEqual, "price")
+		})
+		Convey("a missing field should be reported as not found", func() {
+			schema.Fields = append(schema.Fields, ParserSchema{Id: "missing", Path: []string{"nope"}})
+			rows, err := ex.ExtractRows(NewJSONData(productsJSON))
+			So(err, ShouldBeNil)
+			So(rows[0].Data["missing"], ShouldBeNil)
+			So(errors.Is(rows[0].Errors, ErrFieldNotFound), ShouldBeTrue)
+		})
+		Convey("a limit should cap the number of rows", func() {
+			schema.Limit = 1
+			rows, err := ex.Rows(NewJSONData(productsJSON))
+			So(err, ShouldBeNil)
+			So(len(rows), ShouldEqual, 1)
+		})
+		Convey("data without the rows path should fail", func() {
+			_, err := ex.ExtractRows(NewJSONData([]byte(`{"total": 0}`)))
+			So(err, ShouldEqual, ErrNoData)
+		})
+	})
+	Convey("an invalid parser schema should not load", t, func() {
+		So(NewParserSchemaFromString(`{"path": `), ShouldBeNil)
+	})
+}
+
+func TestRowSchema(t *testing.T) {
+	Convey("given a rest scraper configured with a row schema", t, func() {
+		schema := NewParserSchemaFromString(`{"path": ["data", "items"], "fields": [{"id": "title", "path": ["name"], "type": "string"}]}`)
+		s := NewRestScraper(nil, RowSchema(schema))
+		Convey("rows should be extracted from the response", func() {
+			rows, err := s.GetRows(productsJSON)
+			So(err, ShouldBeNil)
+			So(rows, ShouldResemble, []interface{}{
+				map[string]interface{}{"title": "Blue Kettle"},
+				map[string]interface{}{"title": "Red Kettle"},
+			})
+			row, err := s.ParseRow(rows[0])
+			So(err, ShouldBeNil)
+			So(row, ShouldResemble, rows[0])
+		})
+	})
+}
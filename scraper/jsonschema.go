@@ -0,0 +1,115 @@
+package scraper
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// SchemaTypes returns every type a schema node can have, the built in types followed by the
+// custom types of DefaultTypes
+func SchemaTypes() []string {
+	var builtin, custom []string
+	for name := range builtinTypes {
+		if name != "" {
+			builtin = append(builtin, name)
+		}
+	}
+	for _, name := range DefaultTypes.Names() {
+		if !builtinTypes[name] {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(builtin)
+	sort.Strings(custom)
+	return append(builtin, custom...)
+}
+
+// SchemaJSONSchema returns a JSON Schema document describing the Schema format, it can be used
+// by editors to validate and autocomplete schema files
+func SchemaJSONSchema() ([]byte, error) {
+	doc := jsonSchemaFor(reflect.TypeOf(Schema{}), SchemaTypes())
+	doc["$schema"] = jsonSchemaDraft
+	doc["title"] = "Schema"
+	doc["description"] = "grapple html scraping schema"
+	doc["required"] = []string{"css"}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// ParserSchemaJSONSchema returns a JSON Schema document describing the ParserSchema format, custom
+// types are left out of the type enum as they are given to each ParserSchema by its Parser options
+func ParserSchemaJSONSchema() ([]byte, error) {
+	doc := jsonSchemaFor(reflect.TypeOf(ParserSchema{}), parserSchemaTypes)
+	doc["$schema"] = jsonSchemaDraft
+	doc["title"] = "ParserSchema"
+	doc["description"] = "grapple json parsing schema"
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// jsonSchemaFor describes a schema struct whose type field is one of types, fields of the struct
+// type itself refer back to the root
+func jsonSchemaFor(t reflect.Type, types []string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := jsonSchemaType(field.Type, t)
+		switch name {
+		case "type":
+			property["enum"] = types
+		case "selector":
+			property["enum"] = []string{CSS_SELECTOR, XPATH_SELECTOR, JSONPATH_SELECTOR, REGEX_SELECTOR}
+		}
+		if description := field.Tag.Get("description"); description != "" {
+			property["description"] = description
+		}
+		properties[name] = property
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+func jsonSchemaType(t, root reflect.Type) map[string]interface{} {
+	if t == root {
+		return map[string]interface{}{"$ref": "#"}
+	}
+	if t == reflect.TypeOf(TransformStep{}) {
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "string", "enum": TransformNames()},
+			map[string]interface{}{"type": "object", "minProperties": 1, "maxProperties": 1},
+		}}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaType(t.Elem(), root)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaType(t.Elem(), root)}
+	case reflect.Ptr:
+		return jsonSchemaType(t.Elem(), root)
+	}
+	return map[string]interface{}{}
+}
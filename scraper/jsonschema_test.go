@@ -0,0 +1,52 @@
+package scraper
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchemaJSONSchema(t *testing.T) {
+	Convey("given a registered custom type", t, func() {
+		AddCustomType("jsonschema_test", func(property *Schema, sel *goquery.Selection) interface{} {
+			return nil
+		})
+		Reset(func() {
+			DefaultTypes.Remove("jsonschema_test")
+		})
+		Convey("the schema json schema should describe every field", func() {
+			data, err := SchemaJSONSchema()
+			So(err, ShouldBeNil)
+			var doc map[string]interface{}
+			So(json.Unmarshal(data, &doc), ShouldBeNil)
+			So(doc["additionalProperties"], ShouldEqual, false)
+			properties := doc["properties"].(map[string]interface{})
+			So(properties, ShouldContainKey, "css")
+			So(properties, ShouldContainKey, "properties")
+			So(properties, ShouldNotContainKey, "propertis")
+			So(properties["properties"].(map[string]interface{})["items"], ShouldResemble, map[string]interface{}{"$ref": "#"})
+			So(properties["css"].(map[string]interface{})["description"], ShouldEqual, "relative css from parent schema")
+			types := properties["type"].(map[string]interface{})["enum"].([]interface{})
+			So(types, ShouldContain, "property_array")
+			So(types, ShouldContain, "kv")
+			So(types, ShouldContain, "jsonschema_test")
+		})
+		Convey("the parser schema json schema should describe every field", func() {
+			data, err := ParserSchemaJSONSchema()
+			So(err, ShouldBeNil)
+			var doc map[string]interface{}
+			So(json.Unmarshal(data, &doc), ShouldBeNil)
+			properties := doc["properties"].(map[string]interface{})
+			So(properties, ShouldContainKey, "path")
+			So(properties["fields"].(map[string]interface{})["items"], ShouldResemble, map[string]interface{}{"$ref": "#"})
+			types := properties["type"].(map[string]interface{})["enum"]
+			So(types, ShouldContain, "kv")
+			So(types, ShouldContain, "currency")
+			So(types, ShouldNotContain, "jsonld")
+			So(types, ShouldNotContain, "structured")
+			So(types, ShouldNotContain, "jsonschema_test")
+		})
+	})
+}
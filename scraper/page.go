@@ -1,217 +1,369 @@
-package scraper
-
-import (
-	"bytes"
-	"errors"
-	"net/url"
-	"strings"
-
-	"github.com/PuerkitoBio/goquery"
-	"github.com/apex/log"
-	"github.com/robertkrimen/otto"
-	dry "github.com/ungerik/go-dry"
-)
-
-// PageOpt page scraper options
-type PageOpt func(p *PageScraper) *PageScraper
-
-// PageRequest creates a request for a page
-type PageRequest struct {
-	URL    string
-	Method string
-	Params url.Values
-}
-
-var defaultRequestGetter = func(path string) *PageRequest {
-	return &PageRequest{URL: path, Method: "GET"}
-}
-
-// PageScraper scrapes an html page
-type PageScraper struct {
-	schema        *Schema
-	con           Client
-	requestGetter func(path string) *PageRequest
-}
-
-func (p *PageScraper) getProperty(vm *otto.Otto, parentNode *goquery.Selection, property *Schema) interface{} {
-	if parentNode == nil {
-		return nil
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			logger.Error("Unable to compile css path", "css", property.CssPath[0], "err", r)
-		}
-	}()
-	var propertyNode *goquery.Selection
-	path := property.CssPath[0]
-	if path == "" {
-		propertyNode = parentNode
-	} else {
-		propertyNode = parentNode.Find(path)
-	}
-	if propertyNode != nil {
-		switch property.Type {
-		case OBJECT_PROPERTY:
-			var childData = make(map[string]interface{})
-			for _, childProperty := range property.Properties {
-				childData[childProperty.Id] = p.getProperty(vm, propertyNode, &childProperty)
-			}
-			return childData
-		case LONGTEXT_PROPERTY:
-			if len(property.CssPath) > 1 {
-				if val, ok := propertyNode.Attr(property.CssPath[1]); ok {
-					val = stringMinifier(val)
-					return removeInvalidUtf(val)
-				}
-				return nil
-			}
-			return removeInvalidUtf(propertyNode.Text())
-		case IMAGE_PROPERTY:
-			if val, ok := StringValFromCSSPath(property.CssPath, propertyNode); ok {
-				return val
-			}
-			return nil
-		case ARRAY_PROPERTY:
-			var result []string
-			if len(property.CssPath) > 1 {
-				propertyNode.Each(func(i int, s *goquery.Selection) {
-					if val, ok := s.Attr(property.CssPath[1]); ok {
-						result = append(result, cleanText(val))
-					}
-				})
-				// for _, v := range propertyNode.Nodes {
-				// 	if val, ok := v.Attr(property.CssPath[1]); ok {
-				// 		result = append(result, cleanText(val))
-				// 	}
-				// }
-			} else {
-				propertyNode.Each(func(i int, s *goquery.Selection) {
-					result = append(result, cleanText(s.Text()))
-				})
-				// for _, v := range propertyNode.Nodes {
-				// 	result = append(result, cleanText(v.Data))
-				// }
-			}
-			return result
-		case STRING_PROPERTY:
-			if val, ok := StringValFromCSSPath(property.CssPath, propertyNode); ok {
-				return val
-			}
-			return nil
-		case INT_PROPERTY:
-			if len(property.CssPath) > 1 {
-				if val, ok := propertyNode.Attr(property.CssPath[1]); ok {
-					return dry.StringToInt(removeInvalidUtf(val))
-				}
-				return nil
-			}
-			return dry.StringToInt(removeInvalidUtf(propertyNode.Text()))
-		case PROPERTY_ARRAY:
-			props := map[string]interface{}{}
-			//an array of properties with key and value combinations
-			propertyNode.EachWithBreak(func(i int, s *goquery.Selection) bool {
-				//retrieves all items with the same css path then gets the key-value pair
-				//as specified in the property definition
-				//get key
-				var (
-					key string
-					ok  bool
-				)
-				if property.KeyPath[0] == "" {
-
-					key, ok = StringValFromCSSPath(property.KeyPath, s.Clone().Children().Remove().End())
-
-				} else {
-					key, ok = StringValFromCSSPath(property.KeyPath, s.Find(property.KeyPath[0]).First())
-				}
-				if len(key) == 0 || !ok {
-					return true
-				}
-				key = strings.ToLower(strings.Trim(dry.StringReplaceMulti(key, ",", "", ".", "", ":", " ", " ", "_"), "_"))
-				// key = dry.StringToLowerCamelCase(key)
-				//get val
-				val, ok := StringValFromCSSPath(property.ValPath, s.Find(property.ValPath[0]).First())
-				if !ok {
-					return true
-				}
-				if strings.ToLower(val) == "nil" {
-					return true
-				}
-				if len(val) > 0 {
-					props[key] = val
-				}
-				return true
-			})
-			return props
-		default:
-			//check type formatters
-			if customType, ok := CUSTOM_TYPES[property.Type]; ok {
-				return customType(property, propertyNode)
-			}
-			val, _ := StringValFromCSSPath(property.CssPath, propertyNode)
-			return val
-		}
-	}
-	log.WithFields(log.Fields{
-		"id":  property.Id,
-		"css": property.CssPath,
-	}).Warn("Unable to find property in document")
-	return nil
-}
-
-// ScrapeURL get data from one url
-func (p *PageScraper) ScrapeURL(url string) ([]byte, error) {
-	req := p.requestGetter(url)
-	if req.Method == "POST" {
-		return p.con.PostBytes(req.URL, req.Params)
-	}
-	return p.con.GetBytes(req.URL)
-}
-
-// GetNextURL get rows using goquery
-func (p *PageScraper) GetNextURL(lastURL string, data []byte) (string, error) {
-	return "", errors.New("not implemented")
-}
-
-// GetRows get rows using goquery
-func (p *PageScraper) GetRows(data []byte) ([]interface{}, error) {
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
-	if err == nil {
-		rows := []interface{}{}
-
-		doc.Find(p.schema.CssPath[0]).EachWithBreak(func(i int, s *goquery.Selection) bool {
-			var data = make(map[string]interface{})
-			for _, property := range p.schema.Properties {
-				val := p.getProperty(nil, s, &property)
-				data[property.Id] = val
-			}
-			rows = append(rows, data)
-			return true
-		})
-		return rows, nil
-	}
-	return nil, err
-
-}
-
-// ParseRow parse a single row
-func (p *PageScraper) ParseRow(data interface{}) (interface{}, error) {
-	return nil, errors.New("not implemented")
-}
-
-// SetRequestGetter sets a request getter method for the scraper
-func SetRequestGetter(rg func(path string) *PageRequest) func(p *PageScraper) *PageScraper {
-	return func(p *PageScraper) *PageScraper {
-		p.requestGetter = rg
-		return p
-	}
-}
-
-// NewPageScraper returns a new PageScraper
-func NewPageScraper(con Client, schema *Schema, opts ...PageOpt) *PageScraper {
-	p := &PageScraper{schema: schema, con: con, requestGetter: defaultRequestGetter}
-	for _, opt := range opts {
-		opt(p)
-	}
-	return p
-}
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/apex/log"
+)
+
+// PageOpt page scraper options
+type PageOpt func(p *PageScraper) *PageScraper
+
+// PageRequest creates a request for a page
+type PageRequest struct {
+	URL    string
+	Method string
+	Params url.Values
+}
+
+// RowHook is called by ParseRow with every row after the built in transforms have been applied
+type RowHook func(row map[string]interface{}) (map[string]interface{}, error)
+
+var defaultRequestGetter = func(path string) *PageRequest {
+	return &PageRequest{URL: path, Method: "GET"}
+}
+
+// PageScraper scrapes an html page
+type PageScraper struct {
+	schema        *Schema
+	extractor     *Extractor
+	con           Client
+	requestGetter func(path string) *PageRequest
+	maxPages      int
+	renames       map[string]string
+	dropEmpty     bool
+	hooks         []RowHook
+	rejected      chan<- *RejectedRow
+}
+
+// ScrapeURL get data from one url
+func (p *PageScraper) ScrapeURL(url string) ([]byte, error) {
+	req := p.requestGetter(url)
+	if req.Method == "POST" {
+		return p.con.PostBytes(req.URL, req.Params)
+	}
+	return p.con.GetBytes(req.URL)
+}
+
+// ScrapeURLContext get data from one url, the request is aborted when ctx is done
+func (p *PageScraper) ScrapeURLContext(ctx context.Context, url string) ([]byte, error) {
+	req := p.requestGetter(url)
+	if req.Method == "POST" {
+		return p.con.PostBytesContext(ctx, req.URL, req.Params)
+	}
+	return p.con.GetBytesContext(ctx, req.URL)
+}
+
+// GetNextURL gets the next page url from the schema next css path, relative urls are resolved against
+// the <base> of the page or lastURL. An invalid next path is reported as ErrInvalidSelector
+func (p *PageScraper) GetNextURL(lastURL string, data []byte) (string, error) {
+	if p.schema == nil {
+		return "", ErrNoNextURL
+	}
+	compiled := p.extractor.paths(p.schema).next
+	if compiled == nil {
+		return "", ErrNoNextURL
+	}
+	if err := compiled.err(); err != nil {
+		return "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	path := p.schema.NextPath
+	if len(path) == 1 {
+		path = []string{path[0], "href"}
+	}
+	next, ok := stringValFromPath(path, compiled.re, doc.FindMatcher(compiled.sel).First())
+	if !ok || next == "" {
+		return "", ErrNoNextURL
+	}
+	base, err := url.Parse(lastURL)
+	if err != nil {
+		return "", err
+	}
+	next, err = resolveURL(documentBase(base, doc.Selection), next)
+	if err != nil {
+		return "", err
+	}
+	if next == lastURL {
+		return "", ErrNoNextURL
+	}
+	return next, nil
+}
+
+// MaxPages returns the page limit set with the MaxPages option
+func (p *PageScraper) MaxPages() int {
+	return p.maxPages
+}
+
+// GetRows get rows using goquery, it fails if the schema has an invalid selector. Rows which
+// do not satisfy the schema are sent to the rejected rows channel instead of being returned
+func (p *PageScraper) GetRows(data []byte) ([]interface{}, error) {
+	return p.GetPageRows("", data)
+}
+
+// GetPageRows is like GetRows but resolves relative links against pageURL, the url data was scraped from
+func (p *PageScraper) GetPageRows(pageURL string, data []byte) ([]interface{}, error) {
+	return p.GetPageRowsContext(context.Background(), pageURL, data)
+}
+
+// GetPageRowsContext is like GetPageRows but stops waiting to send a rejected row when ctx is done
+// and then returns ctx.Err()
+func (p *PageScraper) GetPageRowsContext(ctx context.Context, pageURL string, data []byte) ([]interface{}, error) {
+	extracted, err := p.ExtractPageRows(pageURL, data)
+	if err != nil {
+		return nil, err
+	}
+	rows := []interface{}{}
+	for _, row := range extracted {
+		if errors.Is(row.Errors, ErrInvalidSelector) {
+			return nil, row.Errors
+		}
+		if len(row.Reasons) > 0 {
+			if err := p.reject(ctx, &RejectedRow{Index: row.Index, Data: row.Data, Reasons: row.Reasons}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		rows = append(rows, row.Data)
+	}
+	return rows, nil
+}
+
+func (p *PageScraper) reject(ctx context.Context, row *RejectedRow) error {
+	if p.rejected == nil {
+		log.WithFields(log.Fields{
+			"row":     row.Index,
+			"reasons": row.Reasons,
+		}).Warn("Rejected row")
+		return nil
+	}
+	select {
+	case p.rejected <- row:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ExtractRows get rows using goquery along with the errors of the properties that could not be extracted
+func (p *PageScraper) ExtractRows(data []byte) ([]*ExtractedRow, error) {
+	return p.ExtractPageRows("", data)
+}
+
+// ExtractPageRows is like ExtractRows but resolves relative links against pageURL
+func (p *PageScraper) ExtractPageRows(pageURL string, data []byte) ([]*ExtractedRow, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	ex := p.extractor
+	if pageURL != "" {
+		base, err := url.Parse(pageURL)
+		if err != nil {
+			return nil, err
+		}
+		ex = ex.WithBaseURL(base)
+	}
+	return ex.ExtractRows(doc.Selection)
+}
+
+// StructuredData returns the JSON-LD, microdata and OpenGraph data of a page, it does not need a
+// schema. Relative links are resolved against pageURL and json-ld scripts with invalid json are skipped
+func (p *PageScraper) StructuredData(pageURL string, data []byte) (*StructuredData, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var base *url.URL
+	if pageURL != "" {
+		if base, err = url.Parse(pageURL); err != nil {
+			return nil, err
+		}
+	}
+	sd, errs := ExtractStructuredData(doc.Selection, documentBase(base, doc.Selection))
+	for _, err := range errs {
+		log.WithField("url", pageURL).WithError(err).Warn("Skipped structured data")
+	}
+	return sd, nil
+}
+
+// ParseRow parse a single row, values are coerced to their schema type, renamed, dropped if empty
+// and finally passed through the row hooks
+func (p *PageScraper) ParseRow(data interface{}) (interface{}, error) {
+	in, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unable to parse row of type %T", data)
+	}
+	row := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		row[k] = v
+	}
+	if p.schema != nil {
+		for i := range p.schema.Properties {
+			property := &p.schema.Properties[i]
+			val, ok := row[property.Id]
+			if !ok {
+				continue
+			}
+			val, err := coerceValue(property, val)
+			if err != nil {
+				return nil, err
+			}
+			row[property.Id] = val
+		}
+	}
+	for from, to := range p.renames {
+		if val, ok := row[from]; ok {
+			delete(row, from)
+			row[to] = val
+		}
+	}
+	if p.dropEmpty {
+		for k, v := range row {
+			if isEmptyValue(v) {
+				delete(row, k)
+			}
+		}
+	}
+	var err error
+	for _, hook := range p.hooks {
+		if row, err = hook(row); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// coerceValue converts val to the go type of the property schema type
+func coerceValue(property *Schema, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+	switch property.Type {
+	case INT_PROPERTY, FLOAT_PROPERTY, DECIMAL_PROPERTY, BOOL_PROPERTY, DATETIME_PROPERTY, CURRENCY_PROPERTY, URL_PROPERTY:
+		v, err := parseValue(property.Type, property.valueFormat(), val, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert %s: %w", property.Id, err)
+		}
+		return v, nil
+	case STRING_PROPERTY, LONGTEXT_PROPERTY, IMAGE_PROPERTY:
+		if v, ok := val.(string); ok {
+			return v, nil
+		}
+		return fmt.Sprint(val), nil
+	case ARRAY_PROPERTY:
+		switch v := val.(type) {
+		case []string:
+			return v, nil
+		case string:
+			return []string{v}, nil
+		}
+		return nil, fmt.Errorf("unable to convert %s of type %T to array", property.Id, val)
+	}
+	return val, nil
+}
+
+func isEmptyValue(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []string:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// SetRequestGetter sets a request getter method for the scraper
+func SetRequestGetter(rg func(path string) *PageRequest) func(p *PageScraper) *PageScraper {
+	return func(p *PageScraper) *PageScraper {
+		p.requestGetter = rg
+		return p
+	}
+}
+
+// RenameField renames a row field from one id to another in ParseRow
+func RenameField(from, to string) PageOpt {
+	return func(p *PageScraper) *PageScraper {
+		if p.renames == nil {
+			p.renames = map[string]string{}
+		}
+		p.renames[from] = to
+		return p
+	}
+}
+
+// DropEmpty removes nil and empty fields from rows in ParseRow
+func DropEmpty() PageOpt {
+	return func(p *PageScraper) *PageScraper {
+		p.dropEmpty = true
+		return p
+	}
+}
+
+// AddRowHook adds a hook which is called with every row in ParseRow, hooks are called in the order they are added
+func AddRowHook(hook RowHook) PageOpt {
+	return func(p *PageScraper) *PageScraper {
+		p.hooks = append(p.hooks, hook)
+		return p
+	}
+}
+
+// RejectRowsTo sends rows which do not satisfy the schema to rejected, the channel must be
+// read from while the scraper runs. GetRows and GetPageRows wait until it is, Runner and Pipeline
+// stop waiting when their context is done
+func RejectRowsTo(rejected chan<- *RejectedRow) PageOpt {
+	return func(p *PageScraper) *PageScraper {
+		p.rejected = rejected
+		return p
+	}
+}
+
+// MaxPages limits the number of pages a Runner of the scraper scrapes in each run, 0 means no limit.
+// It is the default PageLimit of the runner
+func MaxPages(max int) PageOpt {
+	return func(p *PageScraper) *PageScraper {
+		p.maxPages = max
+		return p
+	}
+}
+
+// ScriptTimeout sets how long the scripts of the schema may run for each value, 0 means no limit.
+// It defaults to DefaultScriptTimeout
+func ScriptTimeout(timeout time.Duration) PageOpt {
+	return func(p *PageScraper) *PageScraper {
+		p.extractor = p.extractor.WithScriptTimeout(timeout)
+		return p
+	}
+}
+
+// Types sets the registry the custom types of the schema are looked up in, DefaultTypes is used by default
+func Types(types *TypeRegistry) PageOpt {
+	return func(p *PageScraper) *PageScraper {
+		p.extractor = p.extractor.WithTypes(types)
+		return p
+	}
+}
+
+// NewPageScraper returns a new PageScraper
+func NewPageScraper(con Client, schema *Schema, opts ...PageOpt) *PageScraper {
+	p := &PageScraper{schema: schema, extractor: NewExtractor(schema), con: con, requestGetter: defaultRequestGetter}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
@@ -1,12 +1,12 @@
 package scraper
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
-
-	"github.com/PuerkitoBio/goquery"
-	"github.com/robertkrimen/otto"
 )
 
 var client Client
@@ -67,28 +67,15 @@ domaininexampleswithoutpriorcoordinationoraskingforpermission.</p>
 </body>
 </html>`
 
-func TestPageScraper_getProperty(t *testing.T) {
-	type args struct {
-		vm         *otto.Otto
-		parentNode *goquery.Selection
-		property   *Schema
-	}
-	tests := []struct {
-		name string
-		p    *PageScraper
-		args args
-		want interface{}
-	}{
-	// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.p.getProperty(tt.args.vm, tt.args.parentNode, tt.args.property); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("PageScraper.getProperty() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
+var listingPage = `<html>
+<body>
+<ul>
+<li>one</li>
+<li>two</li>
+</ul>
+<a class="next" href="list?page=2">Next &raquo;</a>
+</body>
+</html>`
 
 func TestPageScraper_ScrapeURL(t *testing.T) {
 	type args struct {
@@ -135,7 +122,42 @@ func TestPageScraper_GetNextURL(t *testing.T) {
 		want    string
 		wantErr bool
 	}{
-	// TODO: Add test cases.
+		{
+			name: "Test relative next link",
+			p:    NewPageScraper(client, SchemaFromString(`{"css": ["li"], "next": ["a.next", "href"]}`)),
+			args: args{"http://example.com/list?page=1", []byte(listingPage)},
+			want: "http://example.com/list?page=2",
+		},
+		{
+			name: "Test next link defaults to href",
+			p:    NewPageScraper(client, SchemaFromString(`{"css": ["li"], "next": ["a.next"]}`)),
+			args: args{"http://example.com/list?page=1", []byte(listingPage)},
+			want: "http://example.com/list?page=2",
+		},
+		{
+			name: "Test next link regular expression",
+			p:    NewPageScraper(client, SchemaFromString(`{"css": ["li"], "next": ["a.next", "href", "(\\?page=\\d+)"]}`)),
+			args: args{"http://example.com/list?page=1", []byte(listingPage)},
+			want: "http://example.com/list?page=2",
+		},
+		{
+			name:    "Test invalid next link regular expression",
+			p:       NewPageScraper(client, SchemaFromString(`{"css": ["li"], "next": ["a.next", "href", "(page"]}`)),
+			args:    args{"http://example.com/list?page=1", []byte(listingPage)},
+			wantErr: true,
+		},
+		{
+			name:    "Test missing next link",
+			p:       NewPageScraper(client, SchemaFromString(`{"css": ["li"], "next": ["a.previous"]}`)),
+			args:    args{"http://example.com/list?page=1", []byte(listingPage)},
+			wantErr: true,
+		},
+		{
+			name:    "Test schema without next path",
+			p:       NewPageScraper(client, SchemaFromString(`{"css": ["li"]}`)),
+			args:    args{"http://example.com/list?page=1", []byte(listingPage)},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -151,6 +173,49 @@ func TestPageScraper_GetNextURL(t *testing.T) {
 	}
 }
 
+func TestPageScraper_Run(t *testing.T) {
+	// page 1 links to page 2 which links back to page 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next := "2"
+		if r.URL.Query().Get("page") == "2" {
+			next = "1"
+		}
+		fmt.Fprintf(w, `<ul><li>%s</li></ul><a class="next" href="list?page=%s">Next</a>`, r.URL.Query().Get("page"), next)
+	}))
+	defer server.Close()
+	schema := SchemaFromString(`{"css": ["ul"], "next": ["a.next"], "properties": [{"id": "page", "css": ["li"]}]}`)
+	tests := []struct {
+		name      string
+		p         *PageScraper
+		wantPages int
+	}{
+		{
+			name:      "Test a link back to a scraped page ends the run",
+			p:         NewPageScraper(client, schema),
+			wantPages: 2,
+		},
+		{
+			name:      "Test max pages",
+			p:         NewPageScraper(client, schema, MaxPages(1)),
+			wantPages: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewRunner(tt.p, server.URL+"/list?page=1")
+			for run := 0; run < 2; run++ {
+				_, summary := collect(runner.Run())
+				if summary.Err != nil {
+					t.Errorf("Runner.Run() error = %v", summary.Err)
+				}
+				if summary.Pages != tt.wantPages {
+					t.Errorf("run %d: Runner.Run() pages = %v, want %v", run, summary.Pages, tt.wantPages)
+				}
+			}
+		})
+	}
+}
+
 func TestPageScraper_GetRows(t *testing.T) {
 	type args struct {
 		data []byte
@@ -194,6 +259,15 @@ func TestPageScraper_GetRows(t *testing.T) {
 	}
 }
 
+var rowSchema = SchemaFromString(`{
+	"css": ["li"],
+	"properties": [
+		{"id": "title", "type": "string", "css": ["h1"]},
+		{"id": "count", "type": "integer", "css": ["span"]},
+		{"id": "tags", "type": "array", "css": ["a"]}
+	]
+}`)
+
 func TestPageScraper_ParseRow(t *testing.T) {
 	type args struct {
 		data interface{}
@@ -205,7 +279,39 @@ func TestPageScraper_ParseRow(t *testing.T) {
 		want    interface{}
 		wantErr bool
 	}{
-	// TODO: Add test cases.
+		{
+			name: "Test coerce schema types",
+			p:    NewPageScraper(client, rowSchema),
+			args: args{map[string]interface{}{"title": "Example", "count": " 42 ", "tags": "one"}},
+			want: map[string]interface{}{"title": "Example", "count": 42, "tags": []string{"one"}},
+		},
+		{
+			name:    "Test coerce invalid integer",
+			p:       NewPageScraper(client, rowSchema),
+			args:    args{map[string]interface{}{"count": "many"}},
+			wantErr: true,
+		},
+		{
+			name: "Test rename and drop empty fields",
+			p:    NewPageScraper(client, rowSchema, RenameField("title", "name"), DropEmpty()),
+			args: args{map[string]interface{}{"title": "Example", "count": nil, "tags": []string{}}},
+			want: map[string]interface{}{"name": "Example"},
+		},
+		{
+			name: "Test row hook",
+			p: NewPageScraper(client, rowSchema, AddRowHook(func(row map[string]interface{}) (map[string]interface{}, error) {
+				row["source"] = "example"
+				return row, nil
+			})),
+			args: args{map[string]interface{}{"title": "Example"}},
+			want: map[string]interface{}{"title": "Example", "source": "example"},
+		},
+		{
+			name:    "Test row that is not a map",
+			p:       NewPageScraper(client, rowSchema),
+			args:    args{"Example"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
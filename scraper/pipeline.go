@@ -0,0 +1,247 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrSkipItem can be returned by a Transform function to drop an item without stopping the pipeline
+var ErrSkipItem = errors.New("skip item")
+
+// ErrNoScraper is returned by Extract for a page which was not fetched by Fetch
+var ErrNoScraper = errors.New("page has no scraper")
+
+// Page is a page fetched by a pipeline
+type Page struct {
+	URL  string
+	Data []byte
+
+	scraper Scraper
+}
+
+// pipelineRun is the state shared by the stages of a running pipeline, the first error cancels every stage
+type pipelineRun struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	err    error
+}
+
+func (r *pipelineRun) fail(err error) {
+	r.mu.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.mu.Unlock()
+	r.cancel()
+}
+
+func (r *pipelineRun) firstErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// send sends v on out, it returns false if the pipeline was stopped first
+func send[T any](r *pipelineRun, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+// Pipeline is a typed stream of items, stages are chained with Extract and Transform and the
+// pipeline is run by Sink or Collect
+type Pipeline[T any] struct {
+	start func(r *pipelineRun) <-chan T
+}
+
+// stage holds the options of a pipeline stage
+type stage struct {
+	workers    int
+	onRowError func(ctx context.Context, err error) error
+}
+
+// StageOpt pipeline stage options
+type StageOpt func(s *stage) *stage
+
+// Workers sets the number of goroutines running a stage, items may be reordered when it is more than 1
+func Workers(n int) StageOpt {
+	return func(s *stage) *stage {
+		if n > 0 {
+			s.workers = n
+		}
+		return s
+	}
+}
+
+// OnRowError calls fn with the error of every row Extract can not parse or decode, the row is dropped
+// when fn returns nil and the pipeline stops with the error fn returns otherwise. Without it a row
+// error stops the pipeline. fn is called concurrently by a stage with several Workers and other
+// stages ignore it
+func OnRowError(fn func(ctx context.Context, err error) error) StageOpt {
+	return func(s *stage) *stage {
+		s.onRowError = fn
+		return s
+	}
+}
+
+func newStage(opts []StageOpt) *stage {
+	s := &stage{workers: 1}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Fetch starts a pipeline with the page at url and every following page of the scraper, pages are
+// followed like in Runner
+func Fetch(s Scraper, url string, opts ...RunnerOpt) *Pipeline[*Page] {
+	runner := NewRunner(s, url, opts...)
+	return &Pipeline[*Page]{start: func(r *pipelineRun) <-chan *Page {
+		out := make(chan *Page)
+		go func() {
+			defer close(out)
+			err := runner.eachPage(r.ctx, &RunSummary{}, func(url string, data []byte) error {
+				if !send(r, out, &Page{URL: url, Data: data, scraper: s}) {
+					return r.ctx.Err()
+				}
+				return nil
+			})
+			if err != nil {
+				r.fail(err)
+			}
+		}()
+		return out
+	}}
+}
+
+// Extract turns every fetched page into its parsed rows decoded into T with the same rules as
+// DecodeRow. A row which fails to parse or decode stops the pipeline unless OnRowError is given,
+// a page which was not fetched by Fetch stops it with ErrNoScraper
+func Extract[T any](p *Pipeline[*Page], opts ...StageOpt) *Pipeline[T] {
+	st := newStage(opts)
+	return flatten(Transform(p, func(ctx context.Context, page *Page) ([]T, error) {
+		if page.scraper == nil {
+			return nil, fmt.Errorf("%s: %w", page.URL, ErrNoScraper)
+		}
+		rows, err := GetPageRowsContext(ctx, page.scraper, page.URL, page.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", page.URL, err)
+		}
+		rowError := func(err error) error {
+			if st.onRowError == nil {
+				return err
+			}
+			return st.onRowError(ctx, err)
+		}
+		var result []T
+		for i, row := range rows {
+			parsed, err := page.scraper.ParseRow(row)
+			if err == nil && parsed == nil {
+				continue
+			}
+			var v T
+			if err == nil {
+				err = decodeValue(parsed, reflect.ValueOf(&v).Elem())
+			}
+			if err != nil {
+				if err := rowError(fmt.Errorf("%s: row %d: %w", page.URL, i, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}, opts...))
+}
+
+// flatten sends every item of every slice of p
+func flatten[T any](p *Pipeline[[]T]) *Pipeline[T] {
+	return &Pipeline[T]{start: func(r *pipelineRun) <-chan T {
+		in := p.start(r)
+		out := make(chan T)
+		go func() {
+			defer close(out)
+			for items := range in {
+				for _, v := range items {
+					if !send(r, out, v) {
+						return
+					}
+				}
+			}
+		}()
+		return out
+	}}
+}
+
+// Transform applies fn to every item of p. An error stops the whole pipeline unless it is ErrSkipItem
+func Transform[T, U any](p *Pipeline[T], fn func(ctx context.Context, v T) (U, error), opts ...StageOpt) *Pipeline[U] {
+	st := newStage(opts)
+	return &Pipeline[U]{start: func(r *pipelineRun) <-chan U {
+		in := p.start(r)
+		out := make(chan U)
+		var wg sync.WaitGroup
+		for w := 0; w < st.workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for v := range in {
+					u, err := fn(r.ctx, v)
+					if errors.Is(err, ErrSkipItem) {
+						continue
+					}
+					if err != nil {
+						r.fail(err)
+						return
+					}
+					if !send(r, out, u) {
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+		return out
+	}}
+}
+
+// Sink runs the pipeline and calls fn with every item, it returns the first error of any stage or
+// ctx.Err() if ctx is done before the pipeline ends
+func Sink[T any](ctx context.Context, p *Pipeline[T], fn func(ctx context.Context, v T) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r := &pipelineRun{ctx: ctx, cancel: cancel}
+	in := p.start(r)
+	for v := range in {
+		if r.ctx.Err() != nil {
+			continue
+		}
+		if err := fn(r.ctx, v); err != nil {
+			r.fail(err)
+		}
+	}
+	if err := r.firstErr(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Collect runs the pipeline and returns every item
+func Collect[T any](ctx context.Context, p *Pipeline[T]) ([]T, error) {
+	var result []T
+	err := Sink(ctx, p, func(ctx context.Context, v T) error {
+		result = append(result, v)
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// mapScraper wraps pagedScraper to return rows as maps
+type mapScraper struct {
+	*pagedScraper
+}
+
+func (m *mapScraper) ParseRow(data interface{}) (interface{}, error) {
+	row, err := m.pagedScraper.ParseRow(data)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"name": row, "length": len(row.(string))}, nil
+}
+
+type word struct {
+	Name   string `json:"name"`
+	Length int    `json:"length"`
+}
+
+func TestPipeline(t *testing.T) {
+	Convey("given a paginated scraper", t, func() {
+		s := newPagedScraper()
+		ctx := context.Background()
+		// the second page has a row which fails to parse
+		skipFailed := OnRowError(func(ctx context.Context, err error) error { return nil })
+		Convey("fetching and extracting should return every parsed row", func() {
+			var failed []error
+			rows, err := Collect(ctx, Extract[string](Fetch(s, "http://example.com/1"), OnRowError(func(ctx context.Context, err error) error {
+				failed = append(failed, err)
+				return nil
+			})))
+			So(err, ShouldBeNil)
+			So(rows, ShouldResemble, []string{"ONE", "TWO", "THREE", "FOUR"})
+			So(len(failed), ShouldEqual, 1)
+			So(failed[0].Error(), ShouldEqual, "http://example.com/2: row 1: bad row")
+		})
+		Convey("a row which fails to parse should stop the pipeline by default", func() {
+			_, err := Collect(ctx, Extract[string](Fetch(s, "http://example.com/1")))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "http://example.com/2: row 1: bad row")
+		})
+		Convey("a page which was not fetched should stop the pipeline with an error", func() {
+			pages := Transform(Fetch(s, "http://example.com/1"), func(ctx context.Context, page *Page) (*Page, error) {
+				return &Page{URL: page.URL, Data: page.Data}, nil
+			})
+			_, err := Collect(ctx, Extract[string](pages))
+			So(errors.Is(err, ErrNoScraper), ShouldBeTrue)
+		})
+		Convey("a page which links back to a fetched page should end the pipeline", func() {
+			s.next["http://example.com/3"] = "http://example.com/1"
+			pages, err := Collect(ctx, Fetch(s, "http://example.com/1"))
+			So(err, ShouldBeNil)
+			So(len(pages), ShouldEqual, 3)
+		})
+		Convey("a page limit should stop fetching", func() {
+			pages, err := Collect(ctx, Fetch(s, "http://example.com/1", PageLimit(2)))
+			So(err, ShouldBeNil)
+			So(len(pages), ShouldEqual, 2)
+			So(pages[1].URL, ShouldEqual, "http://example.com/2")
+		})
+		Convey("map rows should be decoded into structs", func() {
+			rows, err := Collect(ctx, Extract[word](Fetch(&mapScraper{s}, "http://example.com/1"), skipFailed))
+			So(err, ShouldBeNil)
+			So(rows[2], ShouldResemble, word{Name: "THREE", Length: 5})
+		})
+		Convey("transforms should run concurrently and skip items", func() {
+			words := Extract[string](Fetch(s, "http://example.com/1"), skipFailed)
+			lower := Transform(words, func(ctx context.Context, v string) (string, error) {
+				if v == "TWO" {
+					return "", ErrSkipItem
+				}
+				return strings.ToLower(v), nil
+			}, Workers(3))
+			lengths := Transform(lower, func(ctx context.Context, v string) (int, error) {
+				return len(v), nil
+			})
+			got, err := Collect(ctx, lengths)
+			So(err, ShouldBeNil)
+			sort.Ints(got)
+			So(got, ShouldResemble, []int{3, 4, 5})
+		})
+		Convey("an error in any stage should stop the pipeline", func() {
+			boom := errors.New("boom")
+			words := Transform(Extract[string](Fetch(s, "http://example.com/1"), skipFailed), func(ctx context.Context, v string) (string, error) {
+				if v == "THREE" {
+					return "", boom
+				}
+				return v, nil
+			})
+			var seen []string
+			err := Sink(ctx, words, func(ctx context.Context, v string) error {
+				seen = append(seen, v)
+				return nil
+			})
+			So(err, ShouldEqual, boom)
+			So(seen, ShouldNotContain, "THREE")
+			So(seen, ShouldNotContain, "FOUR")
+		})
+		Convey("a failed fetch should be returned", func() {
+			s.next["http://example.com/3"] = "http://example.com/4"
+			_, err := Collect(ctx, Extract[string](Fetch(s, "http://example.com/1"), skipFailed))
+			So(err, ShouldEqual, ErrNoData)
+		})
+		Convey("cancelling the context should stop the pipeline", func() {
+			ctx, cancel := context.WithCancel(ctx)
+			err := Sink(ctx, Extract[string](Fetch(s, "http://example.com/1"), skipFailed), func(ctx context.Context, v string) error {
+				cancel()
+				return nil
+			})
+			So(err, ShouldEqual, context.Canceled)
+		})
+	})
+}
@@ -0,0 +1,134 @@
+package scraper
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit configures how often requests can be made to a single host
+type RateLimit struct {
+	// RequestsPerSecond is the rate tokens are added to the bucket of a host, 0 means no limit
+	RequestsPerSecond float64
+	// Burst is the number of requests which can be made at once after a host was idle, it defaults to 1
+	Burst int
+	// MinDelay is the least time between the start of two requests to a host
+	MinDelay time.Duration
+	// Jitter is the upper bound of a random delay added to every request
+	Jitter time.Duration
+}
+
+// enabled reports whether the limit delays requests at all
+func (l RateLimit) enabled() bool {
+	return l.RequestsPerSecond > 0 || l.MinDelay > 0 || l.Jitter > 0
+}
+
+// hostBucket is the token bucket of a host
+type hostBucket struct {
+	tokens float64
+	// updated is when tokens was last computed
+	updated time.Time
+	// next is the earliest start of the next request, it enforces MinDelay
+	next time.Time
+	// delay is the least time between requests set for the host, see SetHostDelay
+	delay time.Duration
+}
+
+// RateLimiter is a token bucket rate limiter per host, it is safe for concurrent use and can be
+// shared by several clients
+type RateLimiter struct {
+	limit RateLimit
+	mu    sync.Mutex
+	hosts map[string]*hostBucket
+	rnd   *rand.Rand
+	now   func() time.Time
+}
+
+// NewRateLimiter returns a rate limiter which applies limit to every host
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &RateLimiter{
+		limit: limit,
+		hosts: map[string]*hostBucket{},
+		rnd:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:   time.Now,
+	}
+}
+
+// Wait blocks until a request to host can be made, it returns ctx.Err() if ctx is done first
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	delay := l.reserve(strings.ToLower(host))
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitURL is like Wait for the host of rawURL
+func (l *RateLimiter) WaitURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return l.Wait(ctx, u.Host)
+}
+
+// SetHostDelay sets the least time between the start of two requests to host, it is used instead of
+// RateLimit.MinDelay when it is longer. It lets a host ask for a slower pace, like with a robots.txt Crawl-delay
+func (l *RateLimiter) SetHostDelay(host string, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket(strings.ToLower(host), l.now()).delay = delay
+}
+
+// bucket returns the bucket of host, a new bucket is full
+func (l *RateLimiter) bucket(host string, now time.Time) *hostBucket {
+	b, ok := l.hosts[host]
+	if !ok {
+		b = &hostBucket{tokens: float64(l.limit.Burst), updated: now}
+		l.hosts[host] = b
+	}
+	return b
+}
+
+// reserve takes a token from the bucket of host and returns how long to wait before using it
+func (l *RateLimiter) reserve(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b := l.bucket(host, now)
+	start := now
+	if rps := l.limit.RequestsPerSecond; rps > 0 {
+		b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*rps)
+		b.updated = now
+		b.tokens--
+		if b.tokens < 0 {
+			start = now.Add(time.Duration(-b.tokens / rps * float64(time.Second)))
+		}
+	}
+	if start.Before(b.next) {
+		start = b.next
+	}
+	if l.limit.Jitter > 0 {
+		start = start.Add(time.Duration(l.rnd.Int63n(int64(l.limit.Jitter))))
+	}
+	delay := l.limit.MinDelay
+	if b.delay > delay {
+		delay = b.delay
+	}
+	b.next = start.Add(delay)
+	return start.Sub(now)
+}
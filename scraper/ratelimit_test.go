@@ -0,0 +1,103 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeClock is a clock for rate limiter tests which only moves when it is told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestRateLimiter_reserve(t *testing.T) {
+	Convey("given a limiter of 2 requests per second with a burst of 2", t, func() {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		l := NewRateLimiter(RateLimit{RequestsPerSecond: 2, Burst: 2})
+		l.now = clock.Now
+		Convey("the burst is not delayed and the next requests wait for a token", func() {
+			So(l.reserve("a.com"), ShouldEqual, 0)
+			So(l.reserve("a.com"), ShouldEqual, 0)
+			So(l.reserve("a.com"), ShouldEqual, 500*time.Millisecond)
+			So(l.reserve("a.com"), ShouldEqual, time.Second)
+		})
+		Convey("hosts have their own bucket", func() {
+			l.reserve("a.com")
+			l.reserve("a.com")
+			So(l.reserve("b.com"), ShouldEqual, 0)
+		})
+		Convey("tokens are refilled over time up to the burst", func() {
+			l.reserve("a.com")
+			l.reserve("a.com")
+			clock.Add(10 * time.Second)
+			So(l.reserve("a.com"), ShouldEqual, 0)
+			So(l.reserve("a.com"), ShouldEqual, 0)
+			So(l.reserve("a.com"), ShouldEqual, 500*time.Millisecond)
+		})
+	})
+	Convey("given a limiter with a minimum delay and jitter", t, func() {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		l := NewRateLimiter(RateLimit{MinDelay: time.Second, Jitter: 100 * time.Millisecond})
+		l.now = clock.Now
+		first := l.reserve("a.com")
+		second := l.reserve("a.com")
+		So(first, ShouldBeLessThan, 100*time.Millisecond)
+		So(second-first, ShouldBeBetweenOrEqual, time.Second, 1100*time.Millisecond)
+	})
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	Convey("given a limiter with a long minimum delay", t, func() {
+		l := NewRateLimiter(RateLimit{MinDelay: time.Hour})
+		So(l.Wait(context.Background(), "a.com"), ShouldBeNil)
+		Convey("waiting stops when the context is done", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			So(l.Wait(ctx, "a.com"), ShouldResemble, context.DeadlineExceeded)
+		})
+	})
+}
+
+func TestDefaultClient_RateLimit(t *testing.T) {
+	Convey("given a rate limited client used by several goroutines", t, func() {
+		var requests int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+		}))
+		defer server.Close()
+		client, err := NewDefaultClient(&DefaultClient{RateLimit: RateLimit{RequestsPerSecond: 20, Burst: 1}})
+		So(err, ShouldBeNil)
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.GetBytes(server.URL)
+			}()
+		}
+		wg.Wait()
+		So(atomic.LoadInt32(&requests), ShouldEqual, 5)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 200*time.Millisecond)
+	})
+}
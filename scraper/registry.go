@@ -0,0 +1,101 @@
+package scraper
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// TypeFormatterE is a TypeFormatter which can fail, its error is reported as the ExtractError of the property
+type TypeFormatterE func(property *Schema, sel *goquery.Selection) (interface{}, error)
+
+// TypeRegistry holds custom schema types, it is safe for concurrent use. Types which are not
+// registered are looked up in the parent registry
+type TypeRegistry struct {
+	mu     sync.RWMutex
+	types  map[string]TypeFormatterE
+	parent *TypeRegistry
+}
+
+// DefaultTypes is the global type registry used by extractors without a registry of their own and
+// by AddCustomType, it is the parent of registries created with NewTypeRegistry
+var DefaultTypes = &TypeRegistry{types: map[string]TypeFormatterE{}}
+
+// NewTypeRegistry returns an empty registry which falls back to DefaultTypes
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{types: map[string]TypeFormatterE{}, parent: DefaultTypes}
+}
+
+// Add registers a formatter for schema properties of type name, it replaces a type with the same name
+func (r *TypeRegistry) Add(name string, fn TypeFormatter) {
+	r.AddE(name, func(property *Schema, sel *goquery.Selection) (interface{}, error) {
+		return fn(property, sel), nil
+	})
+}
+
+// AddE is like Add for a formatter which can fail
+func (r *TypeRegistry) AddE(name string, fn TypeFormatterE) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[name] = fn
+}
+
+// Remove removes the type name from the registry, the parent registry is not changed
+func (r *TypeRegistry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.types, name)
+}
+
+// Lookup returns the formatter of the type name from the registry or its parents
+func (r *TypeRegistry) Lookup(name string) (TypeFormatterE, bool) {
+	r.mu.RLock()
+	fn, ok := r.types[name]
+	r.mu.RUnlock()
+	if ok {
+		return fn, true
+	}
+	if r.parent != nil {
+		return r.parent.Lookup(name)
+	}
+	if r == DefaultTypes {
+		if fn, ok := CUSTOM_TYPES[name]; ok {
+			return func(property *Schema, sel *goquery.Selection) (interface{}, error) {
+				return fn(property, sel), nil
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// Names returns the sorted names of the types of the registry and its parents
+func (r *TypeRegistry) Names() []string {
+	seen := map[string]bool{}
+	for reg := r; reg != nil; reg = reg.parent {
+		reg.mu.RLock()
+		for name := range reg.types {
+			seen[name] = true
+		}
+		reg.mu.RUnlock()
+		if reg == DefaultTypes {
+			for name := range CUSTOM_TYPES {
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typesOrDefault returns r or DefaultTypes when r is nil
+func typesOrDefault(r *TypeRegistry) *TypeRegistry {
+	if r == nil {
+		return DefaultTypes
+	}
+	return r
+}
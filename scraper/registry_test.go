@@ -0,0 +1,81 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTypeRegistry(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(`<div class="item"><b>7</b></div>`))
+	Convey("given two scoped registries with the same type name", t, func() {
+		AddCustomType("registry_test_global", func(property *Schema, sel *goquery.Selection) interface{} {
+			return "global"
+		})
+		Reset(func() {
+			DefaultTypes.Remove("registry_test_global")
+		})
+		shop, blog := NewTypeRegistry(), NewTypeRegistry()
+		shop.Add("registry_test", func(property *Schema, sel *goquery.Selection) interface{} {
+			return "shop " + sel.Text()
+		})
+		blog.AddE("registry_test", func(property *Schema, sel *goquery.Selection) (interface{}, error) {
+			return nil, fmt.Errorf("%w: blog %s", ErrInvalidValue, sel.Text())
+		})
+		schema := &Schema{CssPath: []string{".item"}, Properties: []Schema{
+			{Id: "scoped", CssPath: []string{"b"}, Type: "registry_test"},
+			{Id: "global", CssPath: []string{"b"}, Type: "registry_test_global"},
+		}}
+		Convey("each extractor uses its own formatter", func() {
+			So(schema.ValidateWith(shop), ShouldBeNil)
+			rows, err := NewExtractor(schema).WithTypes(shop).ExtractRows(doc.Selection)
+			So(err, ShouldBeNil)
+			So(rows[0].Data, ShouldResemble, map[string]interface{}{"scoped": "shop 7", "global": "global"})
+			So(rows[0].Errors, ShouldBeEmpty)
+		})
+		Convey("errors of a formatter are reported for the property", func() {
+			rows, err := NewExtractor(schema).WithTypes(blog).ExtractRows(doc.Selection)
+			So(err, ShouldBeNil)
+			So(rows[0].Data["scoped"], ShouldBeNil)
+			So(rows[0].Errors, ShouldHaveLength, 1)
+			So(rows[0].Errors[0].Id, ShouldEqual, "scoped")
+			So(errors.Is(rows[0].Errors, ErrInvalidValue), ShouldBeTrue)
+		})
+		Convey("scoped types are unknown to the default registry", func() {
+			So(errors.Is(schema.Validate(), ErrUnknownType), ShouldBeTrue)
+			So(shop.Names(), ShouldContain, "registry_test")
+			So(shop.Names(), ShouldContain, "registry_test_global")
+			So(DefaultTypes.Names(), ShouldNotContain, "registry_test")
+		})
+		Convey("a page scraper can be given a registry", func() {
+			p := NewPageScraper(nil, schema, Types(shop))
+			rows, err := p.GetRows([]byte(`<div class="item"><b>8</b></div>`))
+			So(err, ShouldBeNil)
+			So(rows[0].(map[string]interface{})["scoped"], ShouldEqual, "shop 8")
+		})
+	})
+}
+
+func TestTypeRegistry_Concurrent(t *testing.T) {
+	r := NewTypeRegistry()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("concurrent_%d", i%2)
+			r.Add(name, func(property *Schema, sel *goquery.Selection) interface{} { return i })
+			r.Lookup(name)
+			r.Names()
+		}(i)
+	}
+	wg.Wait()
+	if _, ok := r.Lookup("concurrent_1"); !ok {
+		t.Errorf("Lookup() did not find a registered type")
+	}
+}
@@ -1,342 +1,377 @@
-package scraper
-
-import (
-	"errors"
-	"net/url"
-	"testing"
-
-	"github.com/osiloke/grapple/mocks"
-	. "github.com/smartystreets/goconvey/convey"
-	. "github.com/stretchr/testify/mock"
-)
-
-func TestClientError(t *testing.T) {
-	failed := errors.New("failed")
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(nil, failed)
-	Convey("create a rest scraper", t, func() {
-		s := NewRestScraper(&client)
-		Convey("then scrape url that fails", func() {
-			_, err := s.ScrapeURL(URL("http://example.com/v1/json"))
-			Convey("this should return an err", func() {
-				So(err, ShouldEqual, failed)
-			})
-		})
-	})
-}
-
-func TestScrapeURLWithEmptyData(t *testing.T) {
-	// restData := []string{`
-	// 	{}
-	// `}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(nil, nil)
-	Convey("create a rest scraper", t, func() {
-		s := NewRestScraper(&client)
-		Convey("then scrape url that has no data", func() {
-			_, err := s.ScrapeURL(URL("http://example.com/v1/json"))
-			Convey("this should return an ErrNoData", func() {
-				So(err, ShouldEqual, ErrNoData)
-			})
-		})
-	})
-}
-
-func TestScrapeURLWithData(t *testing.T) {
-	restData := `{"name":"val"}`
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return([]byte(restData), nil)
-	Convey("create a rest scraper", t, func() {
-		s := NewRestScraper(&client)
-		Convey("then scrape url that has data", func() {
-			data, _ := s.ScrapeURL(URL("http://example.com/v1/json"))
-			Convey("this should return some data", func() {
-				So(string(data), ShouldEqual, restData)
-			})
-		})
-	})
-}
-
-func TestNoNextURL(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) ([]byte, error) {
-		return []byte(restPages[url]), nil
-	})
-	Convey("create a rest scraper", t, func() {
-		s := NewRestScraper(&client)
-		Convey("then get next url ", func() {
-			_, err := s.NextURL(nil, nil)
-			Convey("then get next url", func() {
-				So(err, ShouldEqual, ErrNoNextURL)
-			})
-		})
-	})
-}
-
-func TestNextURL(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) ([]byte, error) {
-		return []byte(restPages[url]), nil
-	})
-	Convey("create a rest scraper", t, func() {
-		s := NewRestScraper(
-			&client,
-			NextURL(func(lastURL *url.URL, data Data) (string, error) {
-				return "http://example.com/v1/json?p=2", nil
-			}),
-		)
-		Convey("then get next url ", func() {
-			nextURL, _ := s.NextURL(nil, nil)
-			Convey("then get next url", func() {
-				So(nextURL, ShouldEqual, "http://example.com/v1/json?p=2")
-			})
-		})
-	})
-}
-
-func TestParseData(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper with row count option", t, func() {
-		s := NewRestScraper(
-			&client,
-			ParseData(func(data []byte) (Data, error) {
-				return NewJSONData(data), nil
-			}),
-		)
-		Convey("scrape url", func() {
-			data, _ := s.ScrapeURL(URL("http://example.com/v1/json"))
-			Convey("then parse data", func() {
-				edata, _ := s.ParseData(data)
-				Convey("then parsed data should be an instance of JSONData", func() {
-					jdata, _ := edata.(*JSONData)
-					So(jdata, ShouldNotBeNil)
-				})
-			})
-		})
-	})
-}
-
-func TestDefaultRowCount(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper with row count option", t, func() {
-		s := NewRestScraper(
-			&client)
-		Convey("scrape url", func() {
-			_, err := s.Total(nil)
-			Convey("then parse data", func() {
-				So(err, ShouldEqual, ErrNoRows)
-			})
-		})
-	})
-}
-func TestDefaultGetRow(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper with row count option", t, func() {
-		s := NewRestScraper(
-			&client)
-		Convey("scrape url", func() {
-			_, err := s.Row(nil)
-			Convey("then parse data", func() {
-				So(err, ShouldEqual, ErrNoRows)
-			})
-		})
-	})
-}
-func TestDefaultParseRows(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper with row count option", t, func() {
-		s := NewRestScraper(
-			&client)
-		Convey("scrape url", func() {
-			_, err := s.Rows(nil)
-			Convey("then parse data", func() {
-				So(err, ShouldEqual, ErrNoRows)
-			})
-		})
-	})
-}
-func TestRowCount(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper with row count option", t, func() {
-		s := NewRestScraper(
-			&client,
-			RowCount(func(data Data) (int, error) {
-				return 3, nil
-			}),
-		)
-		Convey("scrape url", func() {
-			data, _ := s.ScrapeURL(URL("http://example.com/v1/json"))
-			Convey("then parse data", func() {
-				edata, _ := s.ParseData(data)
-				Convey("then get total count from data", func() {
-					total, _ := s.Total(edata)
-					So(total, ShouldEqual, 3)
-				})
-			})
-		})
-	})
-}
-
-func TestGetRows(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper with row count option", t, func() {
-		s := NewRestScraper(
-			&client,
-			RowCount(func(data Data) (int, error) {
-				return int(data.Get("total").(float64)), nil
-			}),
-			ParseData(func(data []byte) (Data, error) {
-				return NewJSONData(data), nil
-			}),
-			GetRows(func(data Data) ([]interface{}, error) {
-				return data.Get("data").([]interface{}), nil
-			}),
-		)
-		Convey("scrape url", func() {
-			data, _ := s.ScrapeURL(URL("http://example.com/v1/json"))
-			Convey("then parse data", func() {
-				edata, _ := s.ParseData(data)
-				Convey("then get total count from data", func() {
-					total, _ := s.Total(edata)
-					So(total, ShouldEqual, 3)
-				})
-			})
-		})
-	})
-}
-
-func TestParseRow(t *testing.T) {
-	restPages := map[string]string{
-		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper with row count option", t, func() {
-		s := NewRestScraper(
-			&client,
-			RowCount(func(data Data) (int, error) {
-				return int(data.Get("total").(float64)), nil
-			}),
-			ParseData(func(data []byte) (Data, error) {
-				return NewJSONData(data), nil
-			}),
-			GetRows(func(data Data) ([]interface{}, error) {
-				return data.Get("data").([]interface{}), nil
-			}),
-			ParseRow(func(row interface{}) (interface{}, error) {
-				return row, nil
-			}),
-		)
-		Convey("scrape url", func() {
-			data, _ := s.ScrapeURL(URL("http://example.com/v1/json"))
-			Convey("then parse data", func() {
-				edata, _ := s.ParseData(data)
-				rows, _ := s.Rows(edata)
-				Convey("then get total count from data", func() {
-					row, _ := s.Row(rows[0])
-					So(row.(map[string]interface{}), ShouldResemble, edata.Get("data").([]interface{})[0])
-				})
-			})
-		})
-	})
-}
-
-func TestEchoDataGet(t *testing.T) {
-	Convey("given echo data", t, func() {
-		e := EchoData{[]byte("daa")}
-		Convey("calling get on any path should return original data", func() {
-			So(string(e.Get("something").([]byte)), ShouldEqual, string(e.data))
-		})
-	})
-}
-
-func TestJSONRestScraperNextURL(t *testing.T) {
-	url1 := "http://example.com/v1/json?p=1"
-	url2 := "http://example.com/v1/json?p=2"
-	restPages := map[string]string{
-		url1: `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
-		url2: `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
-	}
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
-		return []byte(restPages[url])
-	}, nil)
-	Convey("create a rest scraper", t, func() {
-		s := NewJSONRestScraper(
-			&client,
-			`{{$p := .url.Query.Get "p" }}{{$next := add $p 1}}{{setParams .url "p" $next}}`,
-			"total_product_count",
-			"products",
-		)
-		url, _ := url.Parse(url1)
-		Convey("scrape a url", func() {
-
-			data, _ := s.ScrapeURL(URL(url1))
-			Convey("parse data retrieved", func() {
-				edata, _ := s.ParseData(data)
-				Convey("then get next url ", func() {
-					nextURL, err := s.NextURL(url, edata)
-					if err != nil {
-						Println(err)
-					}
-					Convey("then get next url", func() {
-						So(nextURL, ShouldEqual, url2)
-					})
-				})
-			})
-		})
-	})
-}
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/osiloke/grapple/mocks"
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/stretchr/testify/mock"
+)
+
+func TestClientError(t *testing.T) {
+	failed := errors.New("failed")
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(nil, failed)
+	Convey("create a rest scraper", t, func() {
+		s := NewRestScraper(&client)
+		Convey("then scrape url that fails", func() {
+			_, err := s.ScrapeURL("http://example.com/v1/json")
+			Convey("this should return an err", func() {
+				So(err, ShouldEqual, failed)
+			})
+		})
+	})
+}
+
+func TestScrapeURLWithEmptyData(t *testing.T) {
+	// restData := []string{`
+	// 	{}
+	// `}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(nil, nil)
+	Convey("create a rest scraper", t, func() {
+		s := NewRestScraper(&client)
+		Convey("then scrape url that has no data", func() {
+			_, err := s.ScrapeURL("http://example.com/v1/json")
+			Convey("this should return an ErrNoData", func() {
+				So(err, ShouldEqual, ErrNoData)
+			})
+		})
+	})
+}
+
+func TestScrapeURLWithData(t *testing.T) {
+	restData := `{"name":"val"}`
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return([]byte(restData), nil)
+	Convey("create a rest scraper", t, func() {
+		s := NewRestScraper(&client)
+		Convey("then scrape url that has data", func() {
+			data, _ := s.ScrapeURL("http://example.com/v1/json")
+			Convey("this should return some data", func() {
+				So(string(data), ShouldEqual, restData)
+			})
+		})
+	})
+}
+
+func TestNoNextURL(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) ([]byte, error) {
+		return []byte(restPages[url]), nil
+	})
+	Convey("create a rest scraper", t, func() {
+		s := NewRestScraper(&client)
+		Convey("then get next url ", func() {
+			_, err := s.NextURL(nil, nil)
+			Convey("then get next url", func() {
+				So(err, ShouldEqual, ErrNoNextURL)
+			})
+		})
+	})
+}
+
+func TestNextURL(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) ([]byte, error) {
+		return []byte(restPages[url]), nil
+	})
+	Convey("create a rest scraper", t, func() {
+		s := NewRestScraper(
+			&client,
+			NextURL(func(lastURL *url.URL, data Data) (string, error) {
+				return "http://example.com/v1/json?p=2", nil
+			}),
+		)
+		Convey("then get next url ", func() {
+			nextURL, _ := s.NextURL(nil, nil)
+			Convey("then get next url", func() {
+				So(nextURL, ShouldEqual, "http://example.com/v1/json?p=2")
+			})
+		})
+	})
+}
+
+func TestParseData(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper with row count option", t, func() {
+		s := NewRestScraper(
+			&client,
+			ParseData(func(data []byte) (Data, error) {
+				return NewJSONData(data), nil
+			}),
+		)
+		Convey("scrape url", func() {
+			data, _ := s.ScrapeURL("http://example.com/v1/json")
+			Convey("then parse data", func() {
+				edata, _ := s.ParseData(data)
+				Convey("then parsed data should be an instance of JSONData", func() {
+					jdata, _ := edata.(*JSONData)
+					So(jdata, ShouldNotBeNil)
+				})
+			})
+		})
+	})
+}
+
+func TestDefaultRowCount(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper with row count option", t, func() {
+		s := NewRestScraper(
+			&client)
+		Convey("scrape url", func() {
+			_, err := s.Total(nil)
+			Convey("then parse data", func() {
+				So(err, ShouldEqual, ErrNoRows)
+			})
+		})
+	})
+}
+func TestDefaultGetRow(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper with row count option", t, func() {
+		s := NewRestScraper(
+			&client)
+		Convey("scrape url", func() {
+			_, err := s.Row(nil)
+			Convey("then parse data", func() {
+				So(err, ShouldEqual, ErrNoRows)
+			})
+		})
+	})
+}
+func TestDefaultParseRows(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper with row count option", t, func() {
+		s := NewRestScraper(
+			&client)
+		Convey("scrape url", func() {
+			_, err := s.Rows(nil)
+			Convey("then parse data", func() {
+				So(err, ShouldEqual, ErrNoRows)
+			})
+		})
+	})
+}
+func TestRowCount(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper with row count option", t, func() {
+		s := NewRestScraper(
+			&client,
+			RowCount(func(data Data) (int, error) {
+				return 3, nil
+			}),
+		)
+		Convey("scrape url", func() {
+			data, _ := s.ScrapeURL("http://example.com/v1/json")
+			Convey("then parse data", func() {
+				edata, _ := s.ParseData(data)
+				Convey("then get total count from data", func() {
+					total, _ := s.Total(edata)
+					So(total, ShouldEqual, 3)
+				})
+			})
+		})
+	})
+}
+
+func TestGetRows(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper with row count option", t, func() {
+		s := NewRestScraper(
+			&client,
+			RowCount(func(data Data) (int, error) {
+				return int(data.Get("total").(float64)), nil
+			}),
+			ParseData(func(data []byte) (Data, error) {
+				return NewJSONData(data), nil
+			}),
+			GetRows(func(data Data) ([]interface{}, error) {
+				return data.Get("data").([]interface{}), nil
+			}),
+		)
+		Convey("scrape url", func() {
+			data, _ := s.ScrapeURL("http://example.com/v1/json")
+			Convey("then parse data", func() {
+				edata, _ := s.ParseData(data)
+				Convey("then get total count from data", func() {
+					total, _ := s.Total(edata)
+					So(total, ShouldEqual, 3)
+				})
+			})
+		})
+	})
+}
+
+func TestParseRow(t *testing.T) {
+	restPages := map[string]string{
+		"http://example.com/v1/json":     `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		"http://example.com/v1/json?p=2": `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper with row count option", t, func() {
+		s := NewRestScraper(
+			&client,
+			RowCount(func(data Data) (int, error) {
+				return int(data.Get("total").(float64)), nil
+			}),
+			ParseData(func(data []byte) (Data, error) {
+				return NewJSONData(data), nil
+			}),
+			GetRows(func(data Data) ([]interface{}, error) {
+				return data.Get("data").([]interface{}), nil
+			}),
+			ParseRow(func(row interface{}) (interface{}, error) {
+				return row, nil
+			}),
+		)
+		Convey("scrape url", func() {
+			data, _ := s.ScrapeURL("http://example.com/v1/json")
+			Convey("then parse data", func() {
+				edata, _ := s.ParseData(data)
+				rows, _ := s.Rows(edata)
+				Convey("then get total count from data", func() {
+					row, _ := s.Row(rows[0])
+					So(row.(map[string]interface{}), ShouldResemble, edata.Get("data").([]interface{})[0])
+				})
+			})
+		})
+	})
+}
+
+func TestEchoDataGet(t *testing.T) {
+	Convey("given echo data", t, func() {
+		e := EchoData{[]byte("daa")}
+		Convey("calling get on any path should return original data", func() {
+			So(string(e.Get("something").([]byte)), ShouldEqual, string(e.data))
+		})
+	})
+}
+
+func TestJSONRestScraperNextURL(t *testing.T) {
+	url1 := "http://example.com/v1/json?p=1"
+	url2 := "http://example.com/v1/json?p=2"
+	restPages := map[string]string{
+		url1: `{"total":3, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		url2: `{"total":3, "data":[{"name":"four"},{"name":"five"},{"name":"siz"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return(func(url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a rest scraper", t, func() {
+		s := NewJSONRestScraper(
+			&client,
+			`{{$p := .url.Query.Get "p" }}{{$next := add $p 1}}{{setParams .url "p" $next}}`,
+			"total_product_count",
+			"products",
+		)
+		url, _ := url.Parse(url1)
+		Convey("scrape a url", func() {
+
+			data, _ := s.ScrapeURL(url1)
+			Convey("parse data retrieved", func() {
+				edata, _ := s.ParseData(data)
+				Convey("then get next url ", func() {
+					nextURL, err := s.NextURL(url, edata)
+					if err != nil {
+						Println(err)
+					}
+					Convey("then get next url", func() {
+						So(nextURL, ShouldEqual, url2)
+					})
+				})
+			})
+		})
+	})
+}
+
+func TestJSONRestScraperRunner(t *testing.T) {
+	url1 := "http://example.com/v1/json?p=1"
+	url2 := "http://example.com/v1/json?p=2"
+	restPages := map[string]string{
+		url1: `{"total":6, "data":[{"name":"one"},{"name":"two"},{"name":"three"}]}`,
+		url2: `{"total":6, "data":[{"name":"four"},{"name":"five"},{"name":"six"}]}`,
+	}
+	client := mocks.Client{}
+	client.On("GetBytesContext", Anything, AnythingOfType("string")).Return(func(ctx context.Context, url string) []byte {
+		return []byte(restPages[url])
+	}, nil)
+	Convey("create a json rest scraper", t, func() {
+		var s Scraper = NewJSONRestScraper(
+			&client,
+			`{{$p := .url.Query.Get "p" }}{{if eq $p "1"}}{{setParams .url "p" 2}}{{end}}`,
+			"total",
+			"data",
+		)
+		Convey("then run it through a runner", func() {
+			rows, done := NewRunner(s, url1).Run()
+			names := []interface{}{}
+			for row := range rows {
+				names = append(names, row.(map[string]interface{})["name"])
+			}
+			summary := <-done
+			Convey("rows from every page should be returned", func() {
+				So(summary.Err, ShouldBeNil)
+				So(summary.Pages, ShouldEqual, 2)
+				So(names, ShouldResemble, []interface{}{"one", "two", "three", "four", "five", "six"})
+			})
+		})
+	})
+}
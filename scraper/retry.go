@@ -0,0 +1,154 @@
+package scraper
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides whether a failed request is made again
+type RetryPolicy interface {
+	// Retry is called after attempt req, counted from 1, failed with the response resp or the
+	// transport error err, elapsed is the time since the first attempt. It returns how long to wait
+	// before the next attempt or false to give up
+	Retry(req *http.Request, attempt int, elapsed time.Duration, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// ExponentialBackoff retries transport errors and 429 or 5xx responses of idempotent requests with a
+// delay which grows exponentially from InitialInterval, a Retry-After header is used instead of the
+// delay when present.
+// Zero fields use the defaults of DefaultRetryPolicy, a negative MaxRetries never retries and a
+// negative Jitter or MaxElapsedTime disables them
+type ExponentialBackoff struct {
+	// MaxRetries is the most times a request is retried
+	MaxRetries int
+	// InitialInterval is the delay before the first retry
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between retries, it does not cap Retry-After
+	MaxInterval time.Duration
+	// Multiplier is the factor the delay grows by after each retry
+	Multiplier float64
+	// Jitter is the fraction of the delay which is randomized, 0.5 waits between half and one and a
+	// half times the delay
+	Jitter float64
+	// MaxElapsedTime stops retrying when the next attempt would start later than this after the first
+	MaxElapsedTime time.Duration
+	// RetryNonIdempotent also retries requests like POST which are not idempotent and have no
+	// Idempotency-Key header, the server may then act on such a request more than once
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is the policy of a DefaultClient without a RetryPolicy, its MaxRetries is
+// replaced by the Retry of the client
+var DefaultRetryPolicy = ExponentialBackoff{
+	MaxRetries:      3,
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     30 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.5,
+	MaxElapsedTime:  2 * time.Minute,
+}
+
+// NoRetry is a RetryPolicy which never retries
+var NoRetry RetryPolicy = ExponentialBackoff{MaxRetries: -1}
+
+func (b ExponentialBackoff) Retry(req *http.Request, attempt int, elapsed time.Duration, resp *http.Response, err error) (time.Duration, bool) {
+	b = b.withDefaults()
+	if b.MaxRetries < 0 || attempt > b.MaxRetries || !retryable(resp, err) || !b.RetryNonIdempotent && !idempotent(req) {
+		return 0, false
+	}
+	delay, ok := RetryAfter(resp)
+	if !ok {
+		delay = b.delay(attempt)
+	}
+	if b.MaxElapsedTime > 0 && elapsed+delay > b.MaxElapsedTime {
+		return 0, false
+	}
+	return delay, true
+}
+
+func (b ExponentialBackoff) withDefaults() ExponentialBackoff {
+	if b.MaxRetries == 0 {
+		b.MaxRetries = DefaultRetryPolicy.MaxRetries
+	}
+	if b.InitialInterval == 0 {
+		b.InitialInterval = DefaultRetryPolicy.InitialInterval
+	}
+	if b.MaxInterval == 0 {
+		b.MaxInterval = DefaultRetryPolicy.MaxInterval
+	}
+	if b.Multiplier == 0 {
+		b.Multiplier = DefaultRetryPolicy.Multiplier
+	}
+	if b.Jitter == 0 {
+		b.Jitter = DefaultRetryPolicy.Jitter
+	}
+	if b.MaxElapsedTime == 0 {
+		b.MaxElapsedTime = DefaultRetryPolicy.MaxElapsedTime
+	}
+	return b
+}
+
+// delay returns the randomized delay before the retry after attempt
+func (b ExponentialBackoff) delay(attempt int) time.Duration {
+	delay := float64(b.InitialInterval) * math.Pow(b.Multiplier, float64(attempt-1))
+	if delay > float64(b.MaxInterval) {
+		delay = float64(b.MaxInterval)
+	}
+	jitter := math.Min(math.Max(b.Jitter, 0), 1)
+	delay *= 1 - jitter + 2*jitter*rand.Float64()
+	return time.Duration(delay)
+}
+
+// retryable reports whether a request which failed with resp or err can succeed when it is made again
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp != nil && retryableStatus(resp.StatusCode)
+}
+
+// idempotent reports whether req can be sent more than once with the effect of sending it once, like
+// http.Transport a request with an Idempotency-Key header is taken to be idempotent
+func idempotent(req *http.Request) bool {
+	if req == nil {
+		return true
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// retryableStatus reports whether a response with the status code is worth retrying
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500 && code != http.StatusNotImplemented
+}
+
+// RetryAfter returns the delay asked for by the Retry-After header of resp, in seconds or as a date
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	val := resp.Header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(val); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,164 @@
+package scraper
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func request(method string, header ...string) *http.Request {
+	req, _ := http.NewRequest(method, "http://example.com", nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	return req
+}
+
+func response(code int, retryAfter string) *http.Response {
+	resp := &http.Response{StatusCode: code, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return resp
+}
+
+func TestExponentialBackoff_Retry(t *testing.T) {
+	Convey("given an exponential backoff without jitter", t, func() {
+		policy := ExponentialBackoff{MaxRetries: 4, InitialInterval: time.Second, MaxInterval: 5 * time.Second, Jitter: -1}
+		Convey("the delay doubles up to the max interval", func() {
+			for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+				delay, ok := policy.Retry(nil, attempt+1, 0, response(503, ""), nil)
+				So(ok, ShouldBeTrue)
+				So(delay, ShouldEqual, want)
+			}
+		})
+		Convey("it gives up after max retries", func() {
+			_, ok := policy.Retry(nil, 5, 0, response(503, ""), nil)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("transport errors and 429 are retried but other client errors are not", func() {
+			_, ok := policy.Retry(nil, 1, 0, nil, errors.New("connection reset"))
+			So(ok, ShouldBeTrue)
+			_, ok = policy.Retry(nil, 1, 0, response(429, ""), nil)
+			So(ok, ShouldBeTrue)
+			_, ok = policy.Retry(nil, 1, 0, response(404, ""), nil)
+			So(ok, ShouldBeFalse)
+			_, ok = policy.Retry(nil, 1, 0, response(501, ""), nil)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("only idempotent requests are retried unless non idempotent ones are allowed", func() {
+			_, ok := policy.Retry(request("GET"), 1, 0, response(503, ""), nil)
+			So(ok, ShouldBeTrue)
+			_, ok = policy.Retry(request("PUT"), 1, 0, nil, errors.New("connection reset"))
+			So(ok, ShouldBeTrue)
+			_, ok = policy.Retry(request("POST"), 1, 0, response(429, ""), nil)
+			So(ok, ShouldBeFalse)
+			_, ok = policy.Retry(request("POST"), 1, 0, nil, errors.New("connection reset"))
+			So(ok, ShouldBeFalse)
+			_, ok = policy.Retry(request("POST", "Idempotency-Key", "a1"), 1, 0, response(503, ""), nil)
+			So(ok, ShouldBeTrue)
+			policy.RetryNonIdempotent = true
+			_, ok = policy.Retry(request("PATCH"), 1, 0, response(503, ""), nil)
+			So(ok, ShouldBeTrue)
+		})
+		Convey("Retry-After is used instead of the backoff", func() {
+			delay, ok := policy.Retry(nil, 1, 0, response(429, "7"), nil)
+			So(ok, ShouldBeTrue)
+			So(delay, ShouldEqual, 7*time.Second)
+			date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+			delay, ok = policy.Retry(nil, 1, 0, response(503, date), nil)
+			So(ok, ShouldBeTrue)
+			So(delay, ShouldBeBetween, 58*time.Second, time.Minute)
+		})
+		Convey("it gives up when the next attempt would start after the max elapsed time", func() {
+			policy.MaxElapsedTime = 10 * time.Second
+			_, ok := policy.Retry(nil, 1, 9*time.Second, response(503, ""), nil)
+			So(ok, ShouldBeTrue)
+			_, ok = policy.Retry(nil, 1, 9*time.Second, response(503, "2"), nil)
+			So(ok, ShouldBeFalse)
+		})
+	})
+	Convey("given the default policy the delay is randomized around the backoff", t, func() {
+		for i := 0; i < 20; i++ {
+			delay, ok := DefaultRetryPolicy.Retry(nil, 2, 0, nil, errors.New("timeout"))
+			So(ok, ShouldBeTrue)
+			So(delay, ShouldBeBetweenOrEqual, 500*time.Millisecond, 1500*time.Millisecond)
+		}
+	})
+	Convey("NoRetry never retries", t, func() {
+		_, ok := NoRetry.Retry(nil, 1, 0, response(503, ""), nil)
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestDefaultClient_Retry(t *testing.T) {
+	Convey("given a server which fails before it succeeds", t, func() {
+		var requests int32
+		failures := int32(2)
+		status := http.StatusServiceUnavailable
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) <= failures {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(status)
+				return
+			}
+			r.ParseForm()
+			w.Write([]byte("ok " + r.Form.Get("q")))
+		}))
+		defer server.Close()
+		client, err := NewDefaultClient(&DefaultClient{RetryPolicy: ExponentialBackoff{InitialInterval: time.Millisecond, RetryNonIdempotent: true}})
+		So(err, ShouldBeNil)
+		Convey("every method retries until it succeeds when non idempotent requests may be retried", func() {
+			data, err := client.GetBytes(server.URL)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "ok ")
+			So(atomic.LoadInt32(&requests), ShouldEqual, 3)
+
+			atomic.StoreInt32(&requests, 0)
+			data, err = client.PostBytes(server.URL, url.Values{"q": {"lamp"}})
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "ok lamp")
+
+			atomic.StoreInt32(&requests, 0)
+			resp, err := client.Get(server.URL)
+			So(err, ShouldBeNil)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			So(string(body), ShouldEqual, "ok ")
+		})
+		Convey("it returns the last http error when retries run out", func() {
+			failures = 10
+			_, err := client.GetBytes(server.URL)
+			var httpErr HTTPError
+			So(errors.As(err, &httpErr), ShouldBeTrue)
+			So(httpErr.Code(), ShouldEqual, 503)
+			So(httpErr.Attempts, ShouldEqual, 4)
+			So(httpErr.IsRetryable(), ShouldBeTrue)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 4)
+		})
+		Convey("a post is not retried by default", func() {
+			client, err := NewDefaultClient(&DefaultClient{RetryPolicy: ExponentialBackoff{InitialInterval: time.Millisecond}})
+			So(err, ShouldBeNil)
+			_, err = client.PostBytes(server.URL, url.Values{"q": {"lamp"}})
+			var httpErr HTTPError
+			So(errors.As(err, &httpErr), ShouldBeTrue)
+			So(httpErr.Code(), ShouldEqual, 503)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+		})
+		Convey("client errors are not retried", func() {
+			status = http.StatusNotFound
+			_, err := client.GetDoc(server.URL)
+			var httpErr HTTPError
+			So(errors.As(err, &httpErr), ShouldBeTrue)
+			So(httpErr.Code(), ShouldEqual, 404)
+			So(atomic.LoadInt32(&requests), ShouldEqual, 1)
+		})
+	})
+}
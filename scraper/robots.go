@@ -0,0 +1,343 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRobotsUserAgent is the name matched against the user-agent lines of robots.txt when a
+// RobotsPolicy has no UserAgent
+const DefaultRobotsUserAgent = "grapple"
+
+// DefaultRobotsCacheTTL is how long a robots.txt is cached when a RobotsPolicy has no CacheTTL
+const DefaultRobotsCacheTTL = 24 * time.Hour
+
+// DefaultRobotsFetchTimeout is how long fetching a robots.txt may take when a RobotsPolicy has no
+// FetchTimeout
+const DefaultRobotsFetchTimeout = 30 * time.Second
+
+// maxRobotsSize is the most of a robots.txt that is read, the rest is ignored
+const maxRobotsSize = 512 * 1024
+
+// RobotsError is returned for a request to a url which is disallowed by the robots.txt of its host,
+// it wraps ErrDisallowed
+type RobotsError struct {
+	URL       string
+	UserAgent string
+}
+
+func (e *RobotsError) Error() string {
+	return fmt.Sprintf("%s for %s by robots.txt: %s", ErrDisallowed, e.UserAgent, e.URL)
+}
+
+func (e *RobotsError) Unwrap() error {
+	return ErrDisallowed
+}
+
+// Doer sends a single http request, *http.Client is a Doer
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// doerFunc is a function which is a Doer
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// RobotsPolicy fetches, caches and applies the robots.txt of every host a client requests, it is
+// safe for concurrent use and can be shared by several clients
+type RobotsPolicy struct {
+	// UserAgent is the name matched against the user-agent lines of robots.txt, only the product
+	// token is used so "grapple/1.0" matches "grapple". It defaults to DefaultRobotsUserAgent
+	UserAgent string
+	// CacheTTL is how long a robots.txt is used before it is fetched again, it defaults to DefaultRobotsCacheTTL
+	CacheTTL time.Duration
+	// IgnoreCrawlDelay stops the Crawl-delay of robots.txt from pacing requests
+	IgnoreCrawlDelay bool
+	// FetchTimeout is how long fetching a robots.txt may take, it defaults to DefaultRobotsFetchTimeout
+	FetchTimeout time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+	now   func() time.Time
+}
+
+// robotsEntry is the cached robots.txt of a host, done is closed once it was fetched
+type robotsEntry struct {
+	done    chan struct{}
+	robots  *Robots
+	err     error
+	expires time.Time
+}
+
+// NewRobotsPolicy returns a policy which matches robots.txt groups by userAgent
+func NewRobotsPolicy(userAgent string) *RobotsPolicy {
+	return &RobotsPolicy{UserAgent: userAgent}
+}
+
+func (p *RobotsPolicy) userAgent() string {
+	if p.UserAgent == "" {
+		return DefaultRobotsUserAgent
+	}
+	return p.UserAgent
+}
+
+func (p *RobotsPolicy) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// Check returns a *RobotsError when the robots.txt of the host of u disallows u and otherwise the
+// crawl delay of the host, client is used to fetch robots.txt when it is not cached
+func (p *RobotsPolicy) Check(ctx context.Context, client Doer, u *url.URL) (time.Duration, error) {
+	if u.Path == "/robots.txt" {
+		return 0, nil
+	}
+	robots, err := p.robots(ctx, client, u)
+	if err != nil {
+		return 0, err
+	}
+	agent := p.userAgent()
+	if !robots.Allowed(agent, u.RequestURI()) {
+		return 0, &RobotsError{URL: u.String(), UserAgent: agent}
+	}
+	if p.IgnoreCrawlDelay {
+		return 0, nil
+	}
+	return robots.CrawlDelay(agent), nil
+}
+
+// robots returns the cached robots.txt of the host of u, requests made while it is fetched wait for
+// it until their own ctx is done
+func (p *RobotsPolicy) robots(ctx context.Context, client Doer, u *url.URL) (*Robots, error) {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+	p.mu.Lock()
+	if p.hosts == nil {
+		p.hosts = map[string]*robotsEntry{}
+	}
+	entry, ok := p.hosts[key]
+	if ok {
+		select {
+		case <-entry.done:
+			ok = entry.err == nil && p.clock().Before(entry.expires)
+		default:
+		}
+	}
+	if !ok {
+		entry = &robotsEntry{done: make(chan struct{})}
+		p.hosts[key] = entry
+		go p.load(ctx, client, key+"/robots.txt", entry)
+	}
+	p.mu.Unlock()
+	select {
+	case <-entry.done:
+		return entry.robots, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load fetches robotsURL into entry. The fetch is shared by every request waiting for entry so it
+// is only stopped by FetchTimeout, not by the ctx of the request which started it
+func (p *RobotsPolicy) load(ctx context.Context, client Doer, robotsURL string, entry *robotsEntry) {
+	timeout := p.FetchTimeout
+	if timeout == 0 {
+		timeout = DefaultRobotsFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	entry.robots, entry.err = p.fetch(ctx, client, robotsURL)
+	ttl := p.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultRobotsCacheTTL
+	}
+	entry.expires = p.clock().Add(ttl)
+	close(entry.done)
+}
+
+// fetch gets and parses a robots.txt. A missing robots.txt allows everything, any other failure like
+// a server error is returned so the request is refused and the next one fetches robots.txt again
+func (p *RobotsPolicy) fetch(ctx context.Context, client Doer, robotsURL string) (*Robots, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return ParseRobots(nil), nil
+	case resp.StatusCode != 200:
+		return nil, newHTTPError(resp, 1)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseRobots(data), nil
+}
+
+// Robots is a parsed robots.txt
+type Robots struct {
+	groups []*robotsGroup
+	// Sitemaps are the urls of the sitemap lines
+	Sitemaps []string
+}
+
+// robotsGroup is the rules which apply to a list of user agents
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// ParseRobots parses the user-agent, allow, disallow, crawl-delay and sitemap lines of a robots.txt,
+// other lines are ignored
+func ParseRobots(data []byte) *Robots {
+	robots := &Robots{}
+	var group *robotsGroup
+	inAgents := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key, val := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				group = &robotsGroup{}
+				robots.groups = append(robots.groups, group)
+				inAgents = true
+			}
+			group.agents = append(group.agents, strings.ToLower(val))
+		case "allow", "disallow":
+			inAgents = false
+			if group == nil || val == "" {
+				continue
+			}
+			group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: val, re: robotsPattern(val)})
+		case "crawl-delay":
+			inAgents = false
+			if group == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(val, 64); err == nil && seconds > 0 {
+				group.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			robots.Sitemaps = append(robots.Sitemaps, val)
+		}
+	}
+	return robots
+}
+
+// robotsPattern compiles a robots.txt path pattern, * matches any characters and a trailing $ ends the path
+func robotsPattern(pattern string) *regexp.Regexp {
+	end := strings.HasSuffix(pattern, "$")
+	pattern = regexp.QuoteMeta(strings.TrimSuffix(pattern, "$"))
+	pattern = "^" + strings.ReplaceAll(pattern, `\*`, ".*")
+	if end {
+		pattern += "$"
+	}
+	return regexp.MustCompile(pattern)
+}
+
+// agentGroups returns the groups of the most specific user-agent line matching userAgent or the
+// groups of * when none matches
+func (r *Robots) agentGroups(userAgent string) []*robotsGroup {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	var (
+		best     []*robotsGroup
+		bestLen  int
+		wildcard []*robotsGroup
+	)
+	for _, group := range r.groups {
+		groupLen := -1
+		for _, agent := range group.agents {
+			switch {
+			case agent == "*":
+				if groupLen < 0 {
+					groupLen = 0
+				}
+			case strings.HasPrefix(token, agent) && len(agent) > groupLen:
+				groupLen = len(agent)
+			}
+		}
+		switch {
+		case groupLen == 0:
+			wildcard = append(wildcard, group)
+		case groupLen > bestLen:
+			best, bestLen = []*robotsGroup{group}, groupLen
+		case groupLen > 0 && groupLen == bestLen:
+			best = append(best, group)
+		}
+	}
+	if best == nil {
+		return wildcard
+	}
+	return best
+}
+
+// Allowed reports whether userAgent may request path, which includes the query. The longest
+// matching rule wins and allow wins a tie
+func (r *Robots) Allowed(userAgent, path string) bool {
+	var (
+		match    *robotsRule
+		matchLen = -1
+	)
+	for _, group := range r.agentGroups(userAgent) {
+		for i, rule := range group.rules {
+			if !rule.re.MatchString(path) {
+				continue
+			}
+			if len(rule.pattern) > matchLen || (len(rule.pattern) == matchLen && rule.allow) {
+				match, matchLen = &group.rules[i], len(rule.pattern)
+			}
+		}
+	}
+	return match == nil || match.allow
+}
+
+// CrawlDelay returns the crawl delay robots.txt asks of userAgent, 0 when it has none
+func (r *Robots) CrawlDelay(userAgent string) time.Duration {
+	var delay time.Duration
+	for _, group := range r.agentGroups(userAgent) {
+		if group.crawlDelay > delay {
+			delay = group.crawlDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,159 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testRobots = `# robots for tests
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: grapple
+User-agent: other
+Disallow: /admin
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestParseRobots(t *testing.T) {
+	Convey("given a robots.txt with a wildcard and a named group", t, func() {
+		robots := ParseRobots([]byte(testRobots))
+		So(robots.Sitemaps, ShouldResemble, []string{"https://example.com/sitemap.xml"})
+		Convey("agents without a group of their own use the wildcard group", func() {
+			So(robots.Allowed("bot", "/"), ShouldBeTrue)
+			So(robots.Allowed("bot", "/private/page"), ShouldBeFalse)
+			So(robots.Allowed("bot", "/private/open/page"), ShouldBeTrue)
+			So(robots.Allowed("bot", "/files/doc.pdf"), ShouldBeFalse)
+			So(robots.Allowed("bot", "/files/doc.pdf?x=1"), ShouldBeTrue)
+			So(robots.CrawlDelay("bot"), ShouldEqual, 2*time.Second)
+		})
+		Convey("agents are matched by their product token, ignoring case", func() {
+			So(robots.Allowed("Grapple/1.0", "/private/page"), ShouldBeTrue)
+			So(robots.Allowed("Grapple/1.0", "/admin/users"), ShouldBeFalse)
+			So(robots.CrawlDelay("grapple"), ShouldEqual, 500*time.Millisecond)
+		})
+	})
+	Convey("given an empty robots.txt everything is allowed", t, func() {
+		robots := ParseRobots(nil)
+		So(robots.Allowed("grapple", "/anything"), ShouldBeTrue)
+		So(robots.CrawlDelay("grapple"), ShouldEqual, 0)
+	})
+}
+
+func TestDefaultClient_Robots(t *testing.T) {
+	Convey("given a client with a robots policy", t, func() {
+		var fetches int32
+		status := http.StatusOK
+		robotsAgent := make(chan string, 10)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				atomic.AddInt32(&fetches, 1)
+				robotsAgent <- r.Header.Get("User-Agent")
+				w.WriteHeader(status)
+				w.Write([]byte("user-agent: *\ndisallow: /private\ncrawl-delay: 3\n"))
+				return
+			}
+			w.Write([]byte("<html></html>"))
+		}))
+		defer server.Close()
+		c, err := NewDefaultClient(&DefaultClient{
+			Robots:      NewRobotsPolicy("grapple"),
+			UserAgents:  StaticUserAgent("grapple/1.0"),
+			RetryPolicy: NoRetry,
+		})
+		So(err, ShouldBeNil)
+		client := c.(*DefaultClient)
+		Convey("disallowed urls are refused with a robots error", func() {
+			_, err := client.GetBytes(server.URL + "/private/page")
+			var robotsErr *RobotsError
+			So(errors.As(err, &robotsErr), ShouldBeTrue)
+			So(errors.Is(err, ErrDisallowed), ShouldBeTrue)
+			So(robotsErr.UserAgent, ShouldEqual, "grapple")
+			So(robotsErr.URL, ShouldEqual, server.URL+"/private/page")
+		})
+		Convey("robots.txt is fetched once and its crawl delay paces the host", func() {
+			_, err := client.GetBytes(server.URL + "/public")
+			So(err, ShouldBeNil)
+			_, err = client.GetBytes(server.URL + "/private")
+			So(err, ShouldNotBeNil)
+			So(atomic.LoadInt32(&fetches), ShouldEqual, 1)
+			host := server.Listener.Addr().String()
+			So(client.Limiter.hosts[host].delay, ShouldEqual, 3*time.Second)
+		})
+		Convey("a missing robots.txt allows everything", func() {
+			status = http.StatusNotFound
+			_, err := client.GetBytes(server.URL + "/private/page")
+			So(err, ShouldBeNil)
+		})
+		Convey("robots.txt is fetched with the user agent of the client", func() {
+			_, err := client.GetBytes(server.URL + "/public")
+			So(err, ShouldBeNil)
+			So(<-robotsAgent, ShouldEqual, "grapple/1.0")
+		})
+		Convey("a robots.txt server error refuses the request until robots.txt is fetched again", func() {
+			status = http.StatusServiceUnavailable
+			_, err := client.GetBytes(server.URL + "/public")
+			var httpErr HTTPError
+			So(errors.As(err, &httpErr), ShouldBeTrue)
+			So(httpErr.Code(), ShouldEqual, 503)
+			status = http.StatusOK
+			_, err = client.GetBytes(server.URL + "/public")
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&fetches), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestRobotsPolicy_CheckCancel(t *testing.T) {
+	Convey("given a robots.txt which is slow to fetch", t, func() {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.Write([]byte("user-agent: *\ndisallow: /private\n"))
+		}))
+		defer server.Close()
+		policy := NewRobotsPolicy("grapple")
+		u, _ := url.Parse(server.URL + "/private")
+		Convey("cancelling the request which started the fetch should not fail the others", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			first := make(chan error, 1)
+			go func() {
+				_, err := policy.Check(ctx, server.Client(), u)
+				first <- err
+			}()
+			second := make(chan error, 1)
+			go func() {
+				// waits for the fetch started by the first request
+				for {
+					policy.mu.Lock()
+					started := len(policy.hosts) > 0
+					policy.mu.Unlock()
+					if started {
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+				_, err := policy.Check(context.Background(), server.Client(), u)
+				second <- err
+			}()
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+			So(<-first, ShouldEqual, context.Canceled)
+			close(release)
+			So(errors.Is(<-second, ErrDisallowed), ShouldBeTrue)
+		})
+	})
+}
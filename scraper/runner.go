@@ -1,34 +1,144 @@
-package scraper
-
-// func OnNextRow(onNextRow func(row interface{})) func(*RestScraper) error {
-// 	return func(r *RestScraper) error {
-// 		r.onNextRow = onNextRow
-// 		return nil
-// 	}
-// }
-
-type Runner struct {
-	url     string
-	lastURL string
-	scraper Scraper
-}
-
-func (s *Runner) scrape(url string) (rows []interface{}, err error) {
-	data, err := s.scraper.ScrapeURL(s.url)
-	if err != nil {
-		return
-	}
-	rows, err = s.scraper.GetRows(data)
-	if err != nil {
-		return
-	}
-	for k, _row := range rows {
-		row, _ := s.scraper.ParseRow(_row)
-		rows[k] = row
-	}
-	return
-}
-
-func (s *Runner) Run() {
-
-}
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// func OnNextRow(onNextRow func(row interface{})) func(*RestScraper) error {
+// 	return func(r *RestScraper) error {
+// 		r.onNextRow = onNextRow
+// 		return nil
+// 	}
+// }
+
+// RunnerOpt runner options
+type RunnerOpt func(r *Runner) *Runner
+
+// PageLimit sets the maximum number of pages a runner will scrape, 0 means no limit
+func PageLimit(limit int) RunnerOpt {
+	return func(r *Runner) *Runner {
+		r.pageLimit = limit
+		return r
+	}
+}
+
+// maxPager is a Scraper with a default page limit for its runs
+type maxPager interface {
+	MaxPages() int
+}
+
+// RunSummary describes a finished run
+type RunSummary struct {
+	// Pages is the number of pages scraped
+	Pages int
+	// Rows is the number of rows sent on the rows channel
+	Rows int
+	// Failed is the number of rows which could not be parsed
+	Failed int
+	// RowErrors are the errors of the rows which could not be parsed, wrapped with their page url
+	// and index
+	RowErrors []error
+	// LastURL is the last url that was scraped
+	LastURL string
+	// Err is the error that stopped the run, it is nil if the run ended because there were no more pages
+	Err error
+}
+
+// Runner drives a Scraper through every page of a url
+type Runner struct {
+	url       string
+	pageLimit int
+	scraper   Scraper
+}
+
+// eachPage scrapes the runner url and its following pages like Run and calls fn with every page,
+// the pages are counted in summary. It returns the error which ended the run, nil when there were
+// no more pages
+func (s *Runner) eachPage(ctx context.Context, summary *RunSummary, fn func(url string, data []byte) error) error {
+	url := s.url
+	visited := map[string]bool{}
+	for {
+		if s.pageLimit > 0 && summary.Pages >= s.pageLimit || visited[url] {
+			return nil
+		}
+		visited[url] = true
+		data, err := ScrapeURLContext(ctx, s.scraper, url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		summary.LastURL = url
+		summary.Pages++
+		if err := fn(url, data); err != nil {
+			return err
+		}
+		url, err = s.scraper.GetNextURL(summary.LastURL, data)
+		if err != nil {
+			if errors.Is(err, ErrNoNextURL) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Run scrapes the runner url and every following page until the scraper returns ErrNoNextURL, a
+// page links to a page which was already scraped in the run or the page limit is reached. Parsed rows are sent on the first channel, which is closed when
+// the run ends. A single RunSummary is then sent on the second channel, so the rows channel
+// must be drained before waiting for the summary.
+func (s *Runner) Run() (<-chan interface{}, <-chan *RunSummary) {
+	return s.RunContext(context.Background())
+}
+
+// RunContext is like Run but stops as soon as ctx is done, the summary error is then ctx.Err()
+func (s *Runner) RunContext(ctx context.Context) (<-chan interface{}, <-chan *RunSummary) {
+	rows := make(chan interface{})
+	done := make(chan *RunSummary, 1)
+	go func() {
+		summary := &RunSummary{}
+		defer func() {
+			close(rows)
+			done <- summary
+			close(done)
+		}()
+		summary.Err = s.eachPage(ctx, summary, func(url string, data []byte) error {
+			pageRows, err := GetPageRowsContext(ctx, s.scraper, url, data)
+			if err != nil {
+				return err
+			}
+			for i, pageRow := range pageRows {
+				row, err := s.scraper.ParseRow(pageRow)
+				if err != nil {
+					summary.Failed++
+					summary.RowErrors = append(summary.RowErrors, fmt.Errorf("%s: row %d: %w", url, i, err))
+					continue
+				}
+				select {
+				case rows <- row:
+					summary.Rows++
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}()
+	return rows, done
+}
+
+// NewRunner returns a runner which scrapes url and its following pages with scraper, the page limit
+// defaults to the MaxPages of the scraper
+func NewRunner(scraper Scraper, url string, opts ...RunnerOpt) *Runner {
+	r := &Runner{url: url, scraper: scraper}
+	if m, ok := scraper.(maxPager); ok {
+		r.pageLimit = m.MaxPages()
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
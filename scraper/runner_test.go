@@ -0,0 +1,142 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// pagedScraper serves rows from a map of pages, each page links to the next
+type pagedScraper struct {
+	pages   map[string]string
+	next    map[string]string
+	scraped []string
+	// noNext is returned when a page has no next page, ErrNoNextURL when nil
+	noNext error
+}
+
+func (p *pagedScraper) ScrapeURL(url string) ([]byte, error) {
+	p.scraped = append(p.scraped, url)
+	if data, ok := p.pages[url]; ok {
+		return []byte(data), nil
+	}
+	return nil, ErrNoData
+}
+
+func (p *pagedScraper) GetNextURL(lastURL string, data []byte) (string, error) {
+	if next, ok := p.next[lastURL]; ok {
+		return next, nil
+	}
+	if p.noNext != nil {
+		return "", p.noNext
+	}
+	return "", ErrNoNextURL
+}
+
+func (p *pagedScraper) GetRows(data []byte) ([]interface{}, error) {
+	rows := []interface{}{}
+	for _, v := range strings.Split(string(data), ",") {
+		rows = append(rows, v)
+	}
+	return rows, nil
+}
+
+func (p *pagedScraper) ParseRow(data interface{}) (interface{}, error) {
+	switch data.(string) {
+	case "bad":
+		return nil, errors.New("bad row")
+	case "partial":
+		return "PARTIAL", errors.New("partial row")
+	}
+	return strings.ToUpper(data.(string)), nil
+}
+
+func newPagedScraper() *pagedScraper {
+	return &pagedScraper{
+		pages: map[string]string{
+			"http://example.com/1": "one,two",
+			"http://example.com/2": "three,bad",
+			"http://example.com/3": "four",
+		},
+		next: map[string]string{
+			"http://example.com/1": "http://example.com/2",
+			"http://example.com/2": "http://example.com/3",
+		},
+	}
+}
+
+func collect(rows <-chan interface{}, done <-chan *RunSummary) ([]interface{}, *RunSummary) {
+	result := []interface{}{}
+	for row := range rows {
+		result = append(result, row)
+	}
+	return result, <-done
+}
+
+func TestRunnerRun(t *testing.T) {
+	Convey("given a runner over a paginated scraper", t, func() {
+		s := newPagedScraper()
+		Convey("running it should scrape every page", func() {
+			rows, summary := collect(NewRunner(s, "http://example.com/1").Run())
+			So(rows, ShouldResemble, []interface{}{"ONE", "TWO", "THREE", "FOUR"})
+			So(s.scraped, ShouldResemble, []string{"http://example.com/1", "http://example.com/2", "http://example.com/3"})
+			So(summary.Pages, ShouldEqual, 3)
+			So(summary.Rows, ShouldEqual, 4)
+			So(summary.Failed, ShouldEqual, 1)
+			So(summary.RowErrors, ShouldHaveLength, 1)
+			So(summary.RowErrors[0].Error(), ShouldEqual, "http://example.com/2: row 1: bad row")
+			So(summary.LastURL, ShouldEqual, "http://example.com/3")
+			So(summary.Err, ShouldBeNil)
+		})
+		Convey("running it with a page limit should stop early", func() {
+			rows, summary := collect(NewRunner(s, "http://example.com/1", PageLimit(2)).Run())
+			So(rows, ShouldResemble, []interface{}{"ONE", "TWO", "THREE"})
+			So(summary.Pages, ShouldEqual, 2)
+			So(summary.LastURL, ShouldEqual, "http://example.com/2")
+		})
+		Convey("a page that fails should end the run with an error", func() {
+			s.next["http://example.com/3"] = "http://example.com/4"
+			rows, summary := collect(NewRunner(s, "http://example.com/1").Run())
+			So(len(rows), ShouldEqual, 4)
+			So(summary.Err, ShouldEqual, ErrNoData)
+		})
+		Convey("a row which fails to parse should be counted as failed even with a value", func() {
+			s.pages["http://example.com/3"] = "four,partial"
+			rows, summary := collect(NewRunner(s, "http://example.com/1").Run())
+			So(rows, ShouldResemble, []interface{}{"ONE", "TWO", "THREE", "FOUR"})
+			So(summary.Failed, ShouldEqual, 2)
+			So(summary.RowErrors[1].Error(), ShouldEqual, "http://example.com/3: row 1: partial row")
+		})
+		Convey("a wrapped ErrNoNextURL should end the run without an error", func() {
+			s.noNext = fmt.Errorf("last page: %w", ErrNoNextURL)
+			_, summary := collect(NewRunner(s, "http://example.com/1").Run())
+			So(summary.Err, ShouldBeNil)
+			So(summary.Pages, ShouldEqual, 3)
+		})
+		Convey("a runner can be run again", func() {
+			r := NewRunner(s, "http://example.com/1")
+			_, first := collect(r.Run())
+			_, second := collect(r.Run())
+			So(second, ShouldResemble, first)
+		})
+	})
+}
+
+func TestRunnerRunContext(t *testing.T) {
+	Convey("given a runner over a paginated scraper", t, func() {
+		s := newPagedScraper()
+		ctx, cancel := context.WithCancel(context.Background())
+		Convey("cancelling the context should stop the run", func() {
+			rows, done := NewRunner(s, "http://example.com/1").RunContext(ctx)
+			So(<-rows, ShouldEqual, "ONE")
+			cancel()
+			_, summary := collect(rows, done)
+			So(summary.Err, ShouldEqual, context.Canceled)
+			So(summary.Pages, ShouldEqual, 1)
+		})
+	})
+}
@@ -1,56 +1,331 @@
-package scraper
-
-import (
-	"encoding/json"
-	"log"
-)
-
-const (
-	STRING_PROPERTY   = "string"
-	ARRAY_PROPERTY    = "array"
-	URLLIST_PROPERTY  = "urllist"
-	IMAGE_PROPERTY    = "imageurl"
-	LONGTEXT_PROPERTY = "longtext"
-	OBJECT_PROPERTY   = "object"
-	INT_PROPERTY      = "integer"
-	//PROPERTY_ARRAY of properties which can be merged with the parent property
-	PROPERTY_ARRAY = "property_array"
-	//KV_PROPERTY is special, it parses a path as a map[string]interface{} and merges it to its parent data
-	KV_PROPERTY = "kv"
-)
-
-type parserFn func(v interface{}) (interface{}, error)
-
-func Parser(name string, parser parserFn) func(s *ParserSchema) {
-	return func(s *ParserSchema) {
-		s.parsers[name] = parser
-	}
-}
-
-type ParserSchema struct {
-	Id      string         `json:"id" description:"id of field"`
-	Path    []string       `json:"path" description:"relative path from parent schema"`
-	Type    string         `json:"type" description:"type of schema, object, int, string etc"`
-	Key     []string       `json:"key"`
-	Val     []string       `json:"val"`
-	Limit   int            `json:"limit"`
-	Fields  []ParserSchema `json:"fields" description:"sub schema"`
-	parsers map[string]parserFn
-}
-
-func parserSchemaFromString(data string) *ParserSchema {
-	var s ParserSchema
-	if err := json.Unmarshal([]byte(data), &s); err != nil {
-		log.Println("Unable to load schema", "err", err)
-		return nil
-	}
-	return &s
-}
-
-func NewParserSchemaFromString(data string, parsers ...parserFn) *ParserSchema {
-	s := parserSchemaFromString(data)
-	for _, p := range parsers {
-		p(s)
-	}
-	return s
-}
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+	"github.com/oliveagle/jsonpath"
+)
+
+const (
+	STRING_PROPERTY   = "string"
+	ARRAY_PROPERTY    = "array"
+	URLLIST_PROPERTY  = "urllist"
+	IMAGE_PROPERTY    = "imageurl"
+	LONGTEXT_PROPERTY = "longtext"
+	OBJECT_PROPERTY   = "object"
+	INT_PROPERTY      = "integer"
+	//PROPERTY_ARRAY of properties which can be merged with the parent property
+	PROPERTY_ARRAY = "property_array"
+	//KV_PROPERTY is special, it parses a path as a map[string]interface{} and merges it to its parent data
+	KV_PROPERTY = "kv"
+	//FLOAT_PROPERTY is parsed to a float64, the number separators depend on the schema locale
+	FLOAT_PROPERTY = "float"
+	//DECIMAL_PROPERTY is parsed to an exact json.Number
+	DECIMAL_PROPERTY = "decimal"
+	//BOOL_PROPERTY is parsed to a bool from true/false, yes/no, y/n, on/off or 1/0
+	BOOL_PROPERTY = "bool"
+	//DATETIME_PROPERTY is parsed to a time.Time with the schema format, a go time layout
+	DATETIME_PROPERTY = "datetime"
+	//CURRENCY_PROPERTY is parsed to a Money with the amount and the ISO 4217 code of the value
+	CURRENCY_PROPERTY = "currency"
+	//URL_PROPERTY is parsed to an absolute url string
+	URL_PROPERTY = "url"
+	//JSONLD_PROPERTY is the list of schema.org JSON-LD items in the property node
+	JSONLD_PROPERTY = "jsonld"
+	//MICRODATA_PROPERTY is the list of top level microdata items in the property node
+	MICRODATA_PROPERTY = "microdata"
+	//OPENGRAPH_PROPERTY is a map of the OpenGraph meta properties in the property node
+	OPENGRAPH_PROPERTY = "opengraph"
+	//STRUCTURED_PROPERTY is a map with the jsonld, microdata and opengraph data of the property node
+	STRUCTURED_PROPERTY = "structured"
+)
+
+var builtinTypes = map[string]bool{
+	"":                  true,
+	STRING_PROPERTY:     true,
+	ARRAY_PROPERTY:      true,
+	URLLIST_PROPERTY:    true,
+	IMAGE_PROPERTY:      true,
+	LONGTEXT_PROPERTY:   true,
+	OBJECT_PROPERTY:     true,
+	INT_PROPERTY:        true,
+	PROPERTY_ARRAY:      true,
+	KV_PROPERTY:         true,
+	FLOAT_PROPERTY:      true,
+	DECIMAL_PROPERTY:    true,
+	BOOL_PROPERTY:       true,
+	DATETIME_PROPERTY:   true,
+	CURRENCY_PROPERTY:   true,
+	URL_PROPERTY:        true,
+	JSONLD_PROPERTY:     true,
+	MICRODATA_PROPERTY:  true,
+	OPENGRAPH_PROPERTY:  true,
+	STRUCTURED_PROPERTY: true,
+}
+
+// structuredTypes are the types whose value is the structured data of a page
+var structuredTypes = map[string]bool{
+	JSONLD_PROPERTY:     true,
+	MICRODATA_PROPERTY:  true,
+	OPENGRAPH_PROPERTY:  true,
+	STRUCTURED_PROPERTY: true,
+}
+
+type parserFn func(v interface{}) (interface{}, error)
+
+// Parser registers a parser for fields of type name, it converts the raw json value of the field
+func Parser(name string, parser parserFn) func(s *ParserSchema) {
+	return func(s *ParserSchema) {
+		if s.parsers == nil {
+			s.parsers = map[string]parserFn{}
+		}
+		s.parsers[name] = parser
+	}
+}
+
+type ParserSchema struct {
+	Id       string         `json:"id" description:"id of field"`
+	Path     []string       `json:"path" description:"relative path from parent schema"`
+	Type     string         `json:"type" description:"type of schema, object, int, string etc"`
+	Key      []string       `json:"key"`
+	Val      []string       `json:"val"`
+	Limit    int            `json:"limit"`
+	Format   string         `json:"format" description:"go time layout of datetime fields"`
+	Locale   string         `json:"locale" description:"locale of number and datetime fields, e.g. en, de, fr"`
+	Currency string         `json:"currency" description:"ISO 4217 code of currency fields whose value has no symbol or code"`
+	Fields   []ParserSchema `json:"fields" description:"sub schema"`
+	parsers  map[string]parserFn
+}
+
+func parserSchemaFromString(data string) *ParserSchema {
+	var s ParserSchema
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		log.Println("Unable to load schema", "err", err)
+		return nil
+	}
+	return &s
+}
+
+// NewParserSchemaFromString loads a parser schema from json, it returns nil if data is not a valid schema
+func NewParserSchemaFromString(data string, parsers ...func(s *ParserSchema)) *ParserSchema {
+	s := parserSchemaFromString(data)
+	if s == nil {
+		return nil
+	}
+	for _, p := range parsers {
+		p(s)
+	}
+	return s
+}
+
+// RejectedRow is a row which does not satisfy its schema
+type RejectedRow struct {
+	Index   int
+	Data    map[string]interface{}
+	Reasons []string
+}
+
+// ValidateRow applies the defaults of the schema properties to row and returns the reasons row
+// does not satisfy the schema. A required property must be in the row and can only be nil if it
+// is nullable, the properties of a mergeWithParent object are looked up in row itself
+func (s *Schema) ValidateRow(row map[string]interface{}) []string {
+	return s.validateRow(row, "")
+}
+
+func (s *Schema) validateRow(row map[string]interface{}, prefix string) (reasons []string) {
+	for i := range s.Properties {
+		property := &s.Properties[i]
+		id := prefix + property.Id
+		if property.MergeWithParent {
+			reasons = append(reasons, property.validateRow(row, prefix)...)
+			continue
+		}
+		val, ok := row[property.Id]
+		if val == nil && property.Default != nil {
+			val, ok = property.Default, true
+			row[property.Id] = val
+		}
+		if property.Required {
+			if !ok {
+				reasons = append(reasons, id+": required field is missing")
+				continue
+			}
+			if val == nil && !property.Nullable {
+				reasons = append(reasons, id+": required field is null")
+				continue
+			}
+		}
+		if child, ok := val.(map[string]interface{}); ok && property.Type == OBJECT_PROPERTY {
+			reasons = append(reasons, property.validateRow(child, id+".")...)
+		}
+	}
+	return
+}
+
+// Validate checks the whole schema tree, it returns SchemaErrors with the json path of every problem found.
+// Custom types are looked up in DefaultTypes
+func (s *Schema) Validate() error {
+	return s.ValidateWith(DefaultTypes)
+}
+
+// ValidateWith is like Validate but looks up custom types in types
+func (s *Schema) ValidateWith(types *TypeRegistry) error {
+	if errs := s.validate("$", true, "", typesOrDefault(types)); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate checks a schema node, parent is the selector kind of its parent
+func (s *Schema) validate(path string, root bool, parent string, types *TypeRegistry) (errs SchemaErrors) {
+	if !root && s.Id == "" && !s.MergeWithParent {
+		errs = append(errs, &SchemaError{Path: path + ".id", Err: errors.New("missing id")})
+	}
+	if _, ok := types.Lookup(s.Type); !ok && !builtinTypes[s.Type] {
+		errs = append(errs, &SchemaError{Path: path + ".type", Err: fmt.Errorf("%w %q", ErrUnknownType, s.Type)})
+	}
+	switch {
+	case !selectorKinds[s.Selector]:
+		errs = append(errs, &SchemaError{Path: path + ".selector", Err: fmt.Errorf("%w: unknown selector %q", ErrInvalidSelector, s.Selector)})
+	case parent == JSONPATH_SELECTOR && s.Selector != JSONPATH_SELECTOR:
+		errs = append(errs, &SchemaError{Path: path + ".selector", Err: fmt.Errorf("%w: properties of a jsonpath property must use jsonpath", ErrInvalidSelector)})
+	case root && !selectsNodes(s.Selector):
+		errs = append(errs, &SchemaError{Path: path + ".selector", Err: fmt.Errorf("%w: a %s selector can not select rows", ErrInvalidSelector, s.Selector)})
+	case s.Selector == REGEX_SELECTOR && (s.Type == OBJECT_PROPERTY || s.Type == PROPERTY_ARRAY):
+		errs = append(errs, &SchemaError{Path: path + ".selector", Err: fmt.Errorf("%w: a regex selector can not select a %s", ErrInvalidSelector, s.Type)})
+	case !selectsNodes(s.Selector) && structuredTypes[s.Type]:
+		errs = append(errs, &SchemaError{Path: path + ".selector", Err: fmt.Errorf("%w: a %s selector can not select %s data", ErrInvalidSelector, s.Selector, s.Type)})
+	}
+	errs = append(errs, validateSelector(path+".css", s.Selector, s.CssPath)...)
+	if len(s.NextPath) > 0 {
+		errs = append(errs, validatePath(path+".next", s.NextPath)...)
+	}
+	if s.Type == PROPERTY_ARRAY {
+		errs = append(errs, validatePath(path+".key", s.KeyPath)...)
+		errs = append(errs, validatePath(path+".val", s.ValPath)...)
+	}
+	errs = append(errs, validateTransforms(path+".transforms", s.Transforms)...)
+	errs = append(errs, validateTransforms(path+".keyTransforms", s.KeyTransforms)...)
+	if s.Script != "" {
+		if _, err := compileScript(s.Script); err != nil {
+			errs = append(errs, &SchemaError{Path: path + ".script", Err: err})
+		}
+	}
+	for i := range s.Properties {
+		errs = append(errs, s.Properties[i].validate(fmt.Sprintf("%s.properties[%d]", path, i), false, s.Selector, types)...)
+	}
+	return
+}
+
+// selector kinds of a schema css path
+const (
+	CSS_SELECTOR = "css"
+	//XPATH_SELECTOR selects nodes with an xpath expression relative to the parent node
+	XPATH_SELECTOR = "xpath"
+	//JSONPATH_SELECTOR parses the text of the parent node, or its css[1] attribute, as json and
+	//selects a value from it. The properties of a jsonpath object are evaluated against its value
+	JSONPATH_SELECTOR = "jsonpath"
+	//REGEX_SELECTOR matches a regular expression against the text of the parent node, or its css[1]
+	//attribute, the value is the first group or the whole match
+	REGEX_SELECTOR = "regex"
+)
+
+var selectorKinds = map[string]bool{"": true, CSS_SELECTOR: true, XPATH_SELECTOR: true, JSONPATH_SELECTOR: true, REGEX_SELECTOR: true}
+
+// selectsNodes reports whether a selector kind selects nodes rather than values
+func selectsNodes(kind string) bool {
+	return kind == "" || kind == CSS_SELECTOR || kind == XPATH_SELECTOR
+}
+
+// compiledPath is a schema path with its selector and regular expression compiled
+type compiledPath struct {
+	kind     string
+	sel      cascadia.Selector
+	xpath    *xpath.Expr
+	jsonpath *jsonpath.Compiled
+	pattern  *regexp.Regexp
+	re       *regexp.Regexp
+	empty    bool
+	cssErr   error
+	reErr    error
+}
+
+// compilePath compiles a css path
+func compilePath(p []string) *compiledPath {
+	return compileSelector(CSS_SELECTOR, p)
+}
+
+// compileSelector compiles a path whose first element is a selector of the given kind
+func compileSelector(kind string, p []string) *compiledPath {
+	c := &compiledPath{kind: kind}
+	if len(p) == 0 {
+		c.empty = true
+		return c
+	}
+	if p[0] != "" {
+		var err error
+		switch kind {
+		case "", CSS_SELECTOR:
+			c.sel, err = cascadia.Compile(p[0])
+		case XPATH_SELECTOR:
+			c.xpath, err = xpath.Compile(p[0])
+		case JSONPATH_SELECTOR:
+			c.jsonpath, err = jsonpath.Compile(p[0])
+		case REGEX_SELECTOR:
+			c.pattern, err = regexp.Compile(p[0])
+		default:
+			err = fmt.Errorf("unknown selector %q", kind)
+		}
+		if err != nil {
+			c.cssErr = fmt.Errorf("%w: %v", ErrInvalidSelector, err)
+		}
+	} else if !selectsNodes(kind) {
+		c.cssErr = fmt.Errorf("%w: empty %s selector", ErrInvalidSelector, kind)
+	}
+	if len(p) > 2 {
+		if re, err := regexp.Compile(p[2]); err != nil {
+			c.reErr = fmt.Errorf("%w: %v", ErrInvalidSelector, err)
+		} else {
+			c.re = re
+		}
+	}
+	return c
+}
+
+// err returns the first problem of the path
+func (c *compiledPath) err() error {
+	if c.empty {
+		return fmt.Errorf("%w: empty path", ErrInvalidSelector)
+	}
+	if c.cssErr != nil {
+		return c.cssErr
+	}
+	return c.reErr
+}
+
+// validatePath checks that a schema css path has a valid selector and regular expression
+func validatePath(path string, p []string) SchemaErrors {
+	return validateSelector(path, CSS_SELECTOR, p)
+}
+
+// validateSelector checks that a schema path has a valid selector of the given kind and regular expression
+func validateSelector(path, kind string, p []string) SchemaErrors {
+	c := compileSelector(kind, p)
+	if c.empty {
+		return SchemaErrors{{Path: path, Err: c.err()}}
+	}
+	var errs SchemaErrors
+	if c.cssErr != nil {
+		errs = append(errs, &SchemaError{Path: path + "[0]", Err: c.cssErr})
+	}
+	if c.reErr != nil {
+		errs = append(errs, &SchemaError{Path: path + "[2]", Err: c.reErr})
+	}
+	return errs
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    string
+		wantPaths []string
+		wantErr   error
+	}{
+		{
+			name: "Test valid schema",
+			schema: `{
+				"css": [".product"],
+				"next": ["a.next", "href"],
+				"properties": [
+					{"id": "name", "type": "string", "css": ["h2 a"]},
+					{"id": "price", "type": "integer", "css": [".price", "data-price", "(\\d+)"]},
+					{"id": "specs", "type": "property_array", "css": ["dt"], "key": ["b"], "val": [""]}
+				]
+			}`,
+		},
+		{
+			name: "Test invalid selectors and regular expressions",
+			schema: `{
+				"css": ["div[[["],
+				"properties": [
+					{"id": "name", "type": "string", "css": ["h2 a", "href", "(\\d+"]},
+					{"id": "meta", "type": "object", "css": [""], "properties": [
+						{"id": "price", "css": []}
+					]}
+				]
+			}`,
+			wantPaths: []string{"$.css[0]", "$.properties[0].css[2]", "$.properties[1].properties[0].css"},
+			wantErr:   ErrInvalidSelector,
+		},
+		{
+			name: "Test unknown type",
+			schema: `{
+				"css": [".product"],
+				"properties": [{"id": "name", "type": "strnig", "css": ["h2"]}]
+			}`,
+			wantPaths: []string{"$.properties[0].type"},
+			wantErr:   ErrUnknownType,
+		},
+		{
+			name: "Test property array without key path",
+			schema: `{
+				"css": [".product"],
+				"properties": [{"id": "specs", "type": "property_array", "css": ["dt"], "val": ["i"]}]
+			}`,
+			wantPaths: []string{"$.properties[0].key"},
+			wantErr:   ErrInvalidSelector,
+		},
+		{
+			name: "Test property without id",
+			schema: `{
+				"css": [".product"],
+				"properties": [{"type": "string", "css": ["h2"]}]
+			}`,
+			wantPaths: []string{"$.properties[0].id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileSchema(tt.schema)
+			if len(tt.wantPaths) == 0 {
+				if err != nil {
+					t.Errorf("CompileSchema() error = %v, want nil", err)
+				}
+				return
+			}
+			var errs SchemaErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("CompileSchema() error = %v, want SchemaErrors", err)
+			}
+			if len(errs) != len(tt.wantPaths) {
+				t.Fatalf("CompileSchema() error = %v, want %d errors", err, len(tt.wantPaths))
+			}
+			for i, path := range tt.wantPaths {
+				if errs[i].Path != path {
+					t.Errorf("SchemaErrors[%d].Path = %s, want %s", i, errs[i].Path, path)
+				}
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("CompileSchema() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileSchemaInvalidJSON(t *testing.T) {
+	if _, err := CompileSchema(`{"css": [`); err == nil {
+		t.Error("CompileSchema() error = nil, want a json error")
+	}
+}
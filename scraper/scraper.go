@@ -1,14 +1,63 @@
-package scraper
-
-type Scraper interface {
-	ScrapeURL(string) ([]byte, error)
-	GetNextURL(lastUtl string, data []byte) (string, error)
-	GetRows(data []byte) ([]interface{}, error)
-	ParseRow(data interface{}) (interface{}, error)
-}
-
-type Data interface {
-	Get(string) interface{}
-}
-
-//a rest api scraper
+package scraper
+
+import (
+	"context"
+)
+
+type Scraper interface {
+	ScrapeURL(string) ([]byte, error)
+	GetNextURL(lastUtl string, data []byte) (string, error)
+	GetRows(data []byte) ([]interface{}, error)
+	ParseRow(data interface{}) (interface{}, error)
+}
+
+// ContextScraper is a Scraper whose requests can be cancelled with a context
+type ContextScraper interface {
+	Scraper
+	ScrapeURLContext(ctx context.Context, url string) ([]byte, error)
+}
+
+// ScrapeURLContext scrapes url with s, using its context variant when s is a ContextScraper
+func ScrapeURLContext(ctx context.Context, s Scraper, url string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if cs, ok := s.(ContextScraper); ok {
+		return cs.ScrapeURLContext(ctx, url)
+	}
+	return s.ScrapeURL(url)
+}
+
+// PageRowsScraper is a Scraper whose rows depend on the url of the page they were scraped from,
+// for example to resolve relative links
+type PageRowsScraper interface {
+	Scraper
+	GetPageRows(pageURL string, data []byte) ([]interface{}, error)
+}
+
+// GetPageRows gets the rows of data scraped from pageURL with s, using GetPageRows when s is a PageRowsScraper
+func GetPageRows(s Scraper, pageURL string, data []byte) ([]interface{}, error) {
+	if ps, ok := s.(PageRowsScraper); ok {
+		return ps.GetPageRows(pageURL, data)
+	}
+	return s.GetRows(data)
+}
+
+type Data interface {
+	Get(string) interface{}
+}
+
+// ContextPageRowsScraper is a PageRowsScraper whose GetPageRows can be stopped with a context, for
+// example while it waits to hand over a rejected row
+type ContextPageRowsScraper interface {
+	PageRowsScraper
+	GetPageRowsContext(ctx context.Context, pageURL string, data []byte) ([]interface{}, error)
+}
+
+// GetPageRowsContext is like GetPageRows but uses GetPageRowsContext when s is a ContextPageRowsScraper
+func GetPageRowsContext(ctx context.Context, s Scraper, pageURL string, data []byte) ([]interface{}, error) {
+	if cs, ok := s.(ContextPageRowsScraper); ok {
+		return cs.GetPageRowsContext(ctx, pageURL, data)
+	}
+	return GetPageRows(s, pageURL, data)
+}
@@ -1,114 +1,180 @@
-package scraper
-
-import (
-	"bytes"
-	"testing"
-
-	"github.com/PuerkitoBio/goquery"
-	"github.com/osiloke/grapple/mocks"
-	. "github.com/smartystreets/goconvey/convey"
-	. "github.com/stretchr/testify/mock"
-)
-
-var testHtml = `
-<html>
-<body>
-<div>
-</div>
-<table id="customers">
-  <tbody>
-  <tr>
-    <th>Company</th>
-    <th>Contact</th>
-    <th>Country</th>
-  </tr>
-  <tr>
-    <td>Alfreds Futterkiste</td>
-    <td>Maria Anders</td>
-    <td>Germany</td>
-  </tr>
-  <tr>
-    <td>Centro comercial Moctezuma</td>
-    <td>Francisco Chang</td>
-    <td>Mexico</td>
-  </tr>
-  <tr>
-    <td>Ernst Handel</td>
-    <td>Roland Mendel</td>
-    <td>Austria</td>
-  </tr>
-  <tr>
-    <td>Island Trading</td>
-    <td>Helen Bennett</td>
-    <td>UK</td>
-  </tr>
-  <tr>
-    <td>Laughing Bacchus Winecellars</td>
-    <td>Yoshi Tannamuri</td>
-    <td>Canada</td>
-  </tr>
-  <tr>
-    <td>Magazzini Alimentari Riuniti</td>
-    <td>Giovanni Rovelli</td>
-    <td>Italy</td>
-  </tr>
-</tbody></table> 
-</body>
-</html>
-`
-
-func TestScrapeStreamTable(t *testing.T) {
-	r := bytes.NewBufferString(testHtml)
-	doc, _ := goquery.NewDocumentFromReader(r)
-	client := mocks.Client{}
-	client.On("GetBytes", AnythingOfType("string")).Return([]byte(testHtml), nil)
-	client.On("GetDoc", AnythingOfType("string")).Return(doc, nil)
-	SkipConvey("create a job", t, func() {
-
-		var testSchema = SchemaFromString(`
-{
-	"name": "example schema",
-	"id": "example",
-	"type": "object",
-	"css": ["table tr"],
-	"properties": [ 
-	     {
-	     	"id": "company",
-	     	"type": "string",
-	     	"css": ["td:nth-of-type(1)"]
-	     },
-	     {
-	     	"id": "contact",
-	     	"type": "string",
-	     	"css": ["td:nth-of-type(2)"]
-	     },
-	     {
-	     	"id": "country",
-	     	"type": "string",
-	     	"css": ["td:nth-of-type(3)"]
-	     } 
-	]
-
-}
-`)
-		job := Job{
-			Name:      "example scraper",
-			URL:       "http://example.com",
-			JobSchema: testSchema,
-			StopOnFn:  nil,
-			Con:       &client,
-		}
-		Convey("and scrape a web page", func() {
-			rows, err := job.ScrapeStream()
-			if err != nil {
-				panic(err)
-			}
-			Convey("wait for all rows to be scraped", func() {
-				for r := range rows {
-					Println(r)
-				}
-			})
-		})
-
-	})
-}
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/osiloke/grapple/mocks"
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/stretchr/testify/mock"
+)
+
+var testHtml = `
+<html>
+<body>
+<div>
+</div>
+<table id="customers">
+  <tbody>
+  <tr>
+    <th>Company</th>
+    <th>Contact</th>
+    <th>Country</th>
+  </tr>
+  <tr>
+    <td>Alfreds Futterkiste</td>
+    <td>Maria Anders</td>
+    <td>Germany</td>
+  </tr>
+  <tr>
+    <td>Centro comercial Moctezuma</td>
+    <td>Francisco Chang</td>
+    <td>Mexico</td>
+  </tr>
+  <tr>
+    <td>Ernst Handel</td>
+    <td>Roland Mendel</td>
+    <td>Austria</td>
+  </tr>
+  <tr>
+    <td>Island Trading</td>
+    <td>Helen Bennett</td>
+    <td>UK</td>
+  </tr>
+  <tr>
+    <td>Laughing Bacchus Winecellars</td>
+    <td>Yoshi Tannamuri</td>
+    <td>Canada</td>
+  </tr>
+  <tr>
+    <td>Magazzini Alimentari Riuniti</td>
+    <td>Giovanni Rovelli</td>
+    <td>Italy</td>
+  </tr>
+</tbody></table> 
+</body>
+</html>
+`
+
+func TestScrapeStreamTable(t *testing.T) {
+	r := bytes.NewBufferString(testHtml)
+	doc, _ := goquery.NewDocumentFromReader(r)
+	client := mocks.Client{}
+	client.On("GetBytes", AnythingOfType("string")).Return([]byte(testHtml), nil)
+	client.On("GetDoc", AnythingOfType("string")).Return(doc, nil)
+	SkipConvey("create a job", t, func() {
+
+		var testSchema = SchemaFromString(`
+{
+	"name": "example schema",
+	"id": "example",
+	"type": "object",
+	"css": ["table tr"],
+	"properties": [ 
+	     {
+	     	"id": "company",
+	     	"type": "string",
+	     	"css": ["td:nth-of-type(1)"]
+	     },
+	     {
+	     	"id": "contact",
+	     	"type": "string",
+	     	"css": ["td:nth-of-type(2)"]
+	     },
+	     {
+	     	"id": "country",
+	     	"type": "string",
+	     	"css": ["td:nth-of-type(3)"]
+	     } 
+	]
+
+}
+`)
+		job := Job{
+			Name:      "example scraper",
+			URL:       "http://example.com",
+			JobSchema: testSchema,
+			StopOnFn:  nil,
+			Con:       &client,
+		}
+		Convey("and scrape a web page", func() {
+			rows, err := job.ScrapeStream()
+			if err != nil {
+				panic(err)
+			}
+			Convey("wait for all rows to be scraped", func() {
+				for r := range rows {
+					Println(r)
+				}
+			})
+		})
+
+	})
+}
+
+func TestScrapeStreamContextCancel(t *testing.T) {
+	r := bytes.NewBufferString(testHtml)
+	doc, _ := goquery.NewDocumentFromReader(r)
+	client := mocks.Client{}
+	client.On("GetBytesContext", Anything, AnythingOfType("string")).Return([]byte("127.0.0.1"), nil)
+	client.On("GetDocContext", Anything, AnythingOfType("string")).Return(doc, nil)
+	Convey("given a job over a table", t, func() {
+		job := Job{
+			Name: "example scraper",
+			URL:  "http://example.com",
+			JobSchema: SchemaFromString(`{
+				"css": ["table tr"],
+				"properties": [{"id": "company", "type": "string", "css": ["td:nth-of-type(1)"]}]
+			}`),
+			Con: &client,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		Convey("cancelling the context should close the rows channel", func() {
+			rows, err := job.ScrapeStreamContext(ctx)
+			So(err, ShouldBeNil)
+			<-rows
+			cancel()
+			count := 0
+			for range rows {
+				count++
+			}
+			So(count, ShouldBeLessThanOrEqualTo, 1)
+		})
+	})
+}
+
+func TestScrapeStreamRejectedRows(t *testing.T) {
+	r := bytes.NewBufferString(testHtml)
+	doc, _ := goquery.NewDocumentFromReader(r)
+	client := mocks.Client{}
+	client.On("GetBytesContext", Anything, AnythingOfType("string")).Return([]byte("127.0.0.1"), nil)
+	client.On("GetDocContext", Anything, AnythingOfType("string")).Return(doc, nil)
+	Convey("given a job with a required field", t, func() {
+		rejected := make(chan *RejectedRow, 10)
+		job := Job{
+			Name: "example scraper",
+			URL:  "http://example.com",
+			JobSchema: SchemaFromString(`{
+				"css": ["table tr"],
+				"properties": [{"id": "company", "type": "string", "css": ["td:nth-of-type(1)"], "required": true}]
+			}`),
+			Con:      &client,
+			Rejected: rejected,
+		}
+		Convey("the header row should be rejected", func() {
+			rows, err := job.ScrapeStream()
+			So(err, ShouldBeNil)
+			count := 0
+			for range rows {
+				count++
+			}
+			So(count, ShouldEqual, 6)
+			So(len(rejected), ShouldEqual, 1)
+			row := <-rejected
+			So(row.Index, ShouldEqual, 0)
+			So(row.Reasons, ShouldResemble, []string{"company: required field is null"})
+		})
+	})
+}
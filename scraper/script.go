@@ -0,0 +1,192 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/robertkrimen/otto"
+)
+
+// DefaultScriptTimeout is how long a schema script may run before it is interrupted
+var DefaultScriptTimeout = time.Second
+
+// errInterrupted is the panic used to stop a script which ran out of time
+type errInterrupted struct{}
+
+// compileScript compiles the script of a schema, scripts are parsed once and run in the vm of each
+// extraction run
+func compileScript(src string) (*otto.Script, error) {
+	script, err := otto.New().Compile("", src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrScript, err)
+	}
+	return script, nil
+}
+
+// scriptReset is run once in a new vm, it returns a function which deletes the globals created
+// since and restores the ones which were replaced. It holds its own copies of the builtins it uses
+// so a script can not break it
+const scriptReset = `(function (global) {
+	var names = Object.getOwnPropertyNames, saved = Object.create(null), initial = names(global);
+	for (var i = 0; i < initial.length; i++) {
+		saved[initial[i]] = global[initial[i]];
+	}
+	return function () {
+		var current = names(global);
+		for (var i = 0; i < current.length; i++) {
+			var name = current[i];
+			if (name in saved) {
+				if (global[name] !== saved[name]) {
+					global[name] = saved[name];
+				}
+			} else if (!delete global[name]) {
+				global[name] = undefined;
+			}
+		}
+	};
+})(this)`
+
+// scriptFreeze is run once in a new vm, it freezes the builtin objects and their prototypes so a
+// script can not change them for the scripts after it
+const scriptFreeze = `(function (global) {
+	var names = Object.getOwnPropertyNames(global);
+	for (var i = 0; i < names.length; i++) {
+		var value = global[names[i]];
+		if (value !== null && (typeof value === "object" || typeof value === "function")) {
+			Object.freeze(value);
+			if (value.prototype !== null && typeof value.prototype === "object") {
+				Object.freeze(value.prototype);
+			}
+		}
+	}
+})(this)`
+
+// scriptVM is the sandboxed vm the scripts of an extraction run share, it is created by the first
+// script, its builtins are frozen and its globals are reset after every script. It is not safe for
+// concurrent use
+type scriptVM struct {
+	vm    *otto.Otto
+	reset otto.Value
+	parse otto.Value
+}
+
+// init creates the vm with console output discarded and the builtins frozen
+func (s *scriptVM) init() error {
+	vm := otto.New()
+	if _, err := vm.Run("var console = {log: function () {}}"); err != nil {
+		return err
+	}
+	if _, err := vm.Run(scriptFreeze); err != nil {
+		return err
+	}
+	parse, err := vm.Run("JSON.parse")
+	if err != nil {
+		return err
+	}
+	reset, err := vm.Run(scriptReset)
+	if err != nil {
+		return err
+	}
+	vm.Interrupt = make(chan func(), 1)
+	s.vm, s.reset, s.parse = vm, reset, parse
+	return nil
+}
+
+// run runs script with the globals value, html and row and returns the value of its last
+// statement. Go values are copied into the vm as plain json values so the script can not call go
+// methods or change the row and a script that runs longer than timeout is interrupted, the vm is
+// then dropped and the next script gets a new one
+func (s *scriptVM) run(script *otto.Script, timeout time.Duration, value interface{}, html string, row map[string]interface{}) (result interface{}, err error) {
+	if s.vm == nil {
+		if err := s.init(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrScript, err)
+		}
+	}
+	vm := s.vm
+	defer func() {
+		if s.vm != nil {
+			s.reset.Call(otto.UndefinedValue())
+		}
+	}()
+	globals := map[string]interface{}{"value": value, "html": html, "row": row}
+	for name, v := range globals {
+		jsValue, err := s.toJS(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrScript, name, err)
+		}
+		if err := vm.Set(name, jsValue); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrScript, err)
+		}
+	}
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			vm.Interrupt <- func() { panic(errInterrupted{}) }
+		})
+		defer func() {
+			// an interrupt sent after the script ended must not stop the next one
+			if !timer.Stop() && s.vm != nil {
+				<-vm.Interrupt
+			}
+		}()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(errInterrupted); !ok {
+				panic(r)
+			}
+			s.vm = nil
+			result, err = nil, fmt.Errorf("%w after %s", ErrScriptTimeout, timeout)
+		}
+	}()
+	v, err := vm.Run(script)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrScript, err)
+	}
+	if v.IsUndefined() || v.IsNull() {
+		return nil, nil
+	}
+	return v.Export()
+}
+
+// toJS copies v into the vm through json
+func (s *scriptVM) toJS(v interface{}) (otto.Value, error) {
+	if v == nil {
+		return otto.NullValue(), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return otto.Value{}, err
+	}
+	return s.parse.Call(otto.NullValue(), string(data))
+}
+
+// transform runs the script of property with val, the value extracted from parentNode, the html of
+// the property node and the row extracted so far
+func (e *Extractor) transform(parentNode *goquery.Selection, property *Schema, val interface{}, row map[string]interface{}, index int) (interface{}, ExtractErrors) {
+	if property.Script == "" {
+		return val, nil
+	}
+	node := parentNode
+	if selectsNodes(property.Selector) {
+		if found, err := e.find(parentNode, property, index); err == nil {
+			node = found
+		}
+	}
+	return e.script(node, property, val, row, index)
+}
+
+// script runs the script of schema with val, the html of node and row
+func (e *Extractor) script(node *goquery.Selection, schema *Schema, val interface{}, row map[string]interface{}, index int) (interface{}, ExtractErrors) {
+	paths := e.paths(schema)
+	if paths.scriptErr != nil {
+		return nil, ExtractErrors{{Id: schema.Id, CssPath: schema.CssPath, Row: index, Err: paths.scriptErr}}
+	}
+	html, _ := goquery.OuterHtml(node.First())
+	result, err := e.vm.run(paths.script, e.scriptTimeout, val, html, row)
+	if err != nil {
+		return nil, ExtractErrors{{Id: schema.Id, CssPath: schema.CssPath, Row: index, Err: err}}
+	}
+	return result, nil
+}
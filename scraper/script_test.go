@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var scriptPage = `<html><body>
+<div class="item"><h2 data-sku="A-1">  desk lamp </h2><span class="price">19.99</span></div>
+</body></html>`
+
+func TestExtractor_Script(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(scriptPage))
+	Convey("given properties with scripts", t, func() {
+		schema := &Schema{CssPath: []string{".item"}, Properties: []Schema{
+			{Id: "name", CssPath: []string{"h2"}, Type: STRING_PROPERTY, Script: "value.trim().toUpperCase()"},
+			{Id: "sku", CssPath: []string{"h2"}, Script: `html.match(/data-sku="([^"]+)"/)[1]`},
+			{Id: "price", CssPath: []string{".price"}, Type: FLOAT_PROPERTY, Script: "row.name + ' costs ' + value * 2"},
+			{Id: "missing", CssPath: []string{".missing"}, Script: "'never run'"},
+		}}
+		So(schema.Validate(), ShouldBeNil)
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		Convey("the script result replaces the value", func() {
+			So(rows[0].Data["name"], ShouldEqual, "DESK LAMP")
+			So(rows[0].Data["sku"], ShouldEqual, "A-1")
+			So(rows[0].Data["price"], ShouldEqual, "DESK LAMP costs 39.98")
+		})
+		Convey("scripts are not run for values that were not found", func() {
+			So(rows[0].Data["missing"], ShouldBeNil)
+			So(rows[0].Errors, ShouldHaveLength, 1)
+			So(errors.Is(rows[0].Errors[0], ErrFieldNotFound), ShouldBeTrue)
+		})
+	})
+	Convey("given a row script", t, func() {
+		schema := &Schema{CssPath: []string{".item"}, Script: "value.title = value.name; delete value.name; value", Properties: []Schema{
+			{Id: "name", CssPath: []string{"h2"}, Type: STRING_PROPERTY},
+		}}
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows[0].Errors, ShouldBeEmpty)
+		So(rows[0].Data, ShouldResemble, map[string]interface{}{"title": "desk lamp"})
+	})
+	Convey("given a script that never ends", t, func() {
+		schema := &Schema{CssPath: []string{".item"}, Properties: []Schema{
+			{Id: "name", CssPath: []string{"h2"}, Script: "while (true) {}"},
+		}}
+		start := time.Now()
+		rows, _ := NewExtractor(schema).WithScriptTimeout(50 * time.Millisecond).ExtractRows(doc.Selection)
+		So(time.Since(start), ShouldBeLessThan, time.Second)
+		So(rows[0].Data["name"], ShouldBeNil)
+		So(errors.Is(rows[0].Errors, ErrScriptTimeout), ShouldBeTrue)
+	})
+	Convey("given scripts which leave globals behind", t, func() {
+		schema := &Schema{CssPath: []string{".item"}, Properties: []Schema{
+			{Id: "first", CssPath: []string{"h2"}, Script: "var seen = 1; leaked = 2; JSON = null; Math = null; 'first'"},
+			{Id: "second", CssPath: []string{"h2"}, Script: "[typeof seen, typeof leaked, typeof JSON.stringify, Math.max(1, 2)].join()"},
+			{Id: "slow", CssPath: []string{"h2"}, Script: "while (true) {}"},
+			{Id: "after", CssPath: []string{".price"}, Script: "typeof leaked + ' ' + value"},
+		}}
+		rows, _ := NewExtractor(schema).WithScriptTimeout(50 * time.Millisecond).ExtractRows(doc.Selection)
+		Convey("the next script should not see them", func() {
+			So(rows[0].Data["first"], ShouldEqual, "first")
+			So(rows[0].Data["second"], ShouldEqual, "undefined,undefined,function,2")
+		})
+		Convey("a script after a timeout should run in a new vm", func() {
+			So(errors.Is(rows[0].Errors, ErrScriptTimeout), ShouldBeTrue)
+			So(rows[0].Data["after"], ShouldEqual, "undefined 19.99")
+		})
+	})
+	Convey("given a script which changes the builtins the next script should not see it", t, func() {
+		schema := &Schema{CssPath: []string{".item"}, Properties: []Schema{
+			{Id: "first", CssPath: []string{"h2"}, Script: "Array.prototype.map = null; String.prototype.shout = function () { return 'x' }; Object.prototype.get = 1; Math.max = null; 'first'"},
+			{Id: "second", CssPath: []string{"h2"}, Script: "[[1].map(function (v) { return v + 1 })[0], typeof ''.shout, typeof {}.get, Math.max(1, 2)].join()"},
+		}}
+		rows, _ := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(rows[0].Data["first"], ShouldEqual, "first")
+		So(rows[0].Data["second"], ShouldEqual, "2,undefined,undefined,2")
+	})
+	Convey("given a script that changes the row or throws", t, func() {
+		schema := &Schema{CssPath: []string{".item"}, Properties: []Schema{
+			{Id: "name", CssPath: []string{"h2"}, Type: STRING_PROPERTY},
+			{Id: "price", CssPath: []string{".price"}, Script: "row.name = 'changed'; value"},
+			{Id: "fail", CssPath: []string{".price"}, Script: "throw new Error('bad price')"},
+		}}
+		rows, _ := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(rows[0].Data["name"], ShouldEqual, "desk lamp")
+		So(rows[0].Data["price"], ShouldEqual, "19.99")
+		So(errors.Is(rows[0].Errors, ErrScript), ShouldBeTrue)
+	})
+}
+
+func TestSchema_ValidateScript(t *testing.T) {
+	Convey("given a script with a syntax error", t, func() {
+		schema := &Schema{CssPath: []string{"body"}, Properties: []Schema{
+			{Id: "name", CssPath: []string{"h2"}, Script: "value.trim("},
+		}}
+		err := schema.Validate()
+		So(errors.Is(err, ErrScript), ShouldBeTrue)
+		So(err.Error(), ShouldStartWith, "$.properties[0].script")
+	})
+}
@@ -0,0 +1,226 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/oliveagle/jsonpath"
+)
+
+// selectorSource returns the text a value selector is applied to, the css[1] attribute of node or its text
+func selectorSource(node *goquery.Selection, property *Schema) (string, bool) {
+	if len(property.CssPath) > 1 && property.CssPath[1] != "" {
+		return node.Attr(property.CssPath[1])
+	}
+	return node.Text(), true
+}
+
+// jsonPathValue parses the source of node as json and extracts property from it
+func (e *Extractor) jsonPathValue(node *goquery.Selection, property *Schema, index int) (interface{}, ExtractErrors) {
+	fail := func(err error) (interface{}, ExtractErrors) {
+		return nil, ExtractErrors{{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err}}
+	}
+	if err := e.paths(property).css.err(); err != nil {
+		return fail(err)
+	}
+	src, ok := selectorSource(node, property)
+	if !ok {
+		return fail(fmt.Errorf("%w: no attribute %s", ErrFieldNotFound, property.CssPath[1]))
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(src)), &data); err != nil {
+		return fail(fmt.Errorf("%w: %v", ErrInvalidValue, err))
+	}
+	return e.jsonValue(node, data, property, index)
+}
+
+// jsonLookup is path.Lookup which returns an error instead of panicking when an index, slice or
+// filter of path reaches a json null
+func jsonLookup(path *jsonpath.Compiled, data interface{}) (val interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			val, err = nil, fmt.Errorf("%s reaches a null value", path)
+		}
+	}()
+	return path.Lookup(data)
+}
+
+// jsonValue selects property from the json value data and converts it to the property type
+func (e *Extractor) jsonValue(node *goquery.Selection, data interface{}, property *Schema, index int) (interface{}, ExtractErrors) {
+	fail := func(err error) (interface{}, ExtractErrors) {
+		return nil, ExtractErrors{{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err}}
+	}
+	css := e.paths(property).css
+	if err := css.err(); err != nil {
+		return fail(err)
+	}
+	if css.jsonpath == nil {
+		// an unvalidated schema can nest another selector kind in a jsonpath object
+		return fail(fmt.Errorf("%w: properties of a jsonpath property must use jsonpath", ErrInvalidSelector))
+	}
+	val, err := jsonLookup(css.jsonpath, data)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %v", ErrFieldNotFound, err))
+	}
+	if val == nil {
+		return fail(fmt.Errorf("%w: %s is null", ErrFieldNotFound, property.CssPath[0]))
+	}
+	if len(property.Transforms) > 0 && property.Type != OBJECT_PROPERTY {
+		if val, err = applyTransforms(property.Transforms, val); err != nil {
+			return fail(err)
+		}
+	}
+	switch property.Type {
+	case OBJECT_PROPERTY:
+		if len(property.Properties) == 0 {
+			return val, nil
+		}
+		var (
+			row  = make(map[string]interface{})
+			errs ExtractErrors
+		)
+		for i := range property.Properties {
+			child := &property.Properties[i]
+			v, cerrs := e.jsonValue(node, val, child, index)
+			if len(cerrs) == 0 {
+				v, cerrs = e.transform(node, child, v, row, index)
+			}
+			row[child.Id] = v
+			errs = append(errs, cerrs...)
+		}
+		return row, errs
+	case STRING_PROPERTY, LONGTEXT_PROPERTY:
+		return jsonString(val), nil
+	case ARRAY_PROPERTY:
+		items := listItems(val)
+		result := make([]string, len(items))
+		for i, item := range items {
+			result[i] = jsonString(item)
+		}
+		return result, nil
+	case IMAGE_PROPERTY, URLLIST_PROPERTY:
+		return e.resolveAll(node, val, property, index)
+	case "":
+		return val, nil
+	}
+	v, err := parseValue(property.Type, property.valueFormat(), val, documentBase(e.baseURL, node))
+	if err != nil {
+		return fail(err)
+	}
+	return v, nil
+}
+
+// resolveAll resolves the links in val, a single link for an imageurl and a list for a urllist
+func (e *Extractor) resolveAll(node *goquery.Selection, val interface{}, property *Schema, index int) (interface{}, ExtractErrors) {
+	base := documentBase(e.baseURL, node)
+	items := listItems(val)
+	var (
+		links []string
+		errs  ExtractErrors
+	)
+	for _, item := range items {
+		link, err := resolveURL(base, jsonString(item))
+		if err != nil {
+			errs = append(errs, &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err})
+			continue
+		}
+		links = append(links, link)
+	}
+	if property.Type == IMAGE_PROPERTY {
+		if len(links) == 0 {
+			return nil, errs
+		}
+		return links[0], errs
+	}
+	return links, errs
+}
+
+// listItems returns the items of a list or a list with val as its only item
+func listItems(val interface{}) []interface{} {
+	switch v := val.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items
+	}
+	return []interface{}{val}
+}
+
+func jsonString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	return fmt.Sprint(val)
+}
+
+// regexValue matches the regex selector of property against the source of node, arrays get every match
+func (e *Extractor) regexValue(node *goquery.Selection, property *Schema, index int) (interface{}, ExtractErrors) {
+	fail := func(err error) (interface{}, ExtractErrors) {
+		return nil, ExtractErrors{{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err}}
+	}
+	css := e.paths(property).css
+	if err := css.err(); err != nil {
+		return fail(err)
+	}
+	src, ok := selectorSource(node, property)
+	if !ok {
+		return fail(fmt.Errorf("%w: no attribute %s", ErrFieldNotFound, property.CssPath[1]))
+	}
+	var matches []string
+	for _, m := range css.pattern.FindAllStringSubmatch(src, -1) {
+		if len(m) > 1 {
+			matches = append(matches, m[1])
+		} else {
+			matches = append(matches, m[0])
+		}
+	}
+	if len(matches) == 0 {
+		return fail(ErrFieldNotFound)
+	}
+	var val interface{} = matches[0]
+	if property.Type == ARRAY_PROPERTY || property.Type == URLLIST_PROPERTY {
+		items := make([]interface{}, len(matches))
+		for i, m := range matches {
+			items[i] = m
+		}
+		val = items
+	}
+	if len(property.Transforms) > 0 {
+		var err error
+		if val, err = applyTransforms(property.Transforms, val); err != nil {
+			return fail(err)
+		}
+	}
+	switch property.Type {
+	case ARRAY_PROPERTY:
+		if len(property.Transforms) > 0 {
+			return val, nil
+		}
+		for i := range matches {
+			matches[i] = cleanText(matches[i])
+		}
+		return matches, nil
+	case URLLIST_PROPERTY, IMAGE_PROPERTY:
+		return e.resolveAll(node, val, property, index)
+	case "", STRING_PROPERTY, LONGTEXT_PROPERTY:
+		if len(property.Transforms) > 0 {
+			return val, nil
+		}
+		return removeInvalidUtf(stringMinifier(strings.TrimSpace(matches[0]))), nil
+	}
+	v, err := parseValue(property.Type, property.valueFormat(), val, documentBase(e.baseURL, node))
+	if err != nil {
+		return fail(err)
+	}
+	return v, nil
+}
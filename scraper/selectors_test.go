@@ -0,0 +1,167 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var selectorsPage = `<html>
+<head>
+<script type="application/ld+json">{"@type": "Product", "name": "Lamp", "image": ["/img/lamp.png"],
+"offers": {"price": "19.99", "priceCurrency": "EUR"}, "sku": 1042}</script>
+</head>
+<body>
+<table class="specs">
+<tr><th>Colour</th><td>Red</td></tr>
+<tr><th>Weight</th><td>1,5 kg</td></tr>
+</table>
+<p class="stock">Only 3 left, ships in 2 days</p>
+</body>
+</html>`
+
+func TestExtractor_Selectors(t *testing.T) {
+	Convey("given a page with a table, json-ld and text", t, func() {
+		doc, err := goquery.NewDocumentFromReader(bytes.NewBufferString(selectorsPage))
+		So(err, ShouldBeNil)
+		schema := &Schema{CssPath: []string{"//html", ""}, Selector: XPATH_SELECTOR, Properties: []Schema{
+			{Id: "colour", CssPath: []string{`.//th[text()="Colour"]/following-sibling::td`}, Selector: XPATH_SELECTOR, Type: STRING_PROPERTY},
+			{Id: "product", CssPath: []string{"script[type='application/ld+json']"}, Type: OBJECT_PROPERTY, Properties: []Schema{
+				{Id: "name", CssPath: []string{"$.name"}, Selector: JSONPATH_SELECTOR, Type: STRING_PROPERTY},
+				{Id: "sku", CssPath: []string{"$.sku"}, Selector: JSONPATH_SELECTOR, Type: INT_PROPERTY},
+				{Id: "price", CssPath: []string{"$.offers.price"}, Selector: JSONPATH_SELECTOR, Type: DECIMAL_PROPERTY},
+				{Id: "image", CssPath: []string{"$.image[0]"}, Selector: JSONPATH_SELECTOR, Type: IMAGE_PROPERTY},
+				{Id: "offers", CssPath: []string{"$.offers"}, Selector: JSONPATH_SELECTOR, Type: OBJECT_PROPERTY, Properties: []Schema{
+					{Id: "currency", CssPath: []string{"$.priceCurrency"}, Selector: JSONPATH_SELECTOR, Type: STRING_PROPERTY},
+				}},
+			}},
+			{Id: "stock", CssPath: []string{".stock"}, Type: OBJECT_PROPERTY, Properties: []Schema{
+				{Id: "left", CssPath: []string{`Only (\d+) left`}, Selector: REGEX_SELECTOR, Type: INT_PROPERTY},
+				{Id: "numbers", CssPath: []string{`\d+`}, Selector: REGEX_SELECTOR, Type: ARRAY_PROPERTY},
+			}},
+		}}
+		So(schema.Validate(), ShouldBeNil)
+		base, _ := url.Parse("http://example.com/shop/lamp")
+		rows, err := NewExtractor(schema).WithBaseURL(base).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		So(rows[0].Errors, ShouldBeEmpty)
+		So(rows[0].Data, ShouldResemble, map[string]interface{}{
+			"colour": "Red",
+			"product": map[string]interface{}{
+				"name":   "Lamp",
+				"sku":    1042,
+				"price":  json.Number("19.99"),
+				"image":  "http://example.com/img/lamp.png",
+				"offers": map[string]interface{}{"currency": "EUR"},
+			},
+			"stock": map[string]interface{}{
+				"left":    3,
+				"numbers": []string{"3", "2"},
+			},
+		})
+	})
+	Convey("given a regex selector that does not match", t, func() {
+		doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(selectorsPage))
+		schema := &Schema{CssPath: []string{"body"}, Properties: []Schema{
+			{Id: "sold", CssPath: []string{`(\d+) sold`}, Selector: REGEX_SELECTOR},
+		}}
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		So(rows[0].Errors, ShouldHaveLength, 1)
+		So(errors.Is(rows[0].Errors[0], ErrFieldNotFound), ShouldBeTrue)
+	})
+	Convey("given a jsonpath selector over text that is not json", t, func() {
+		doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(selectorsPage))
+		schema := &Schema{CssPath: []string{".stock"}, Properties: []Schema{
+			{Id: "left", CssPath: []string{"$.left"}, Selector: JSONPATH_SELECTOR},
+		}}
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		So(errors.Is(rows[0].Errors[0], ErrInvalidValue), ShouldBeTrue)
+	})
+	Convey("given a jsonpath selector through a null value", t, func() {
+		doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(`<script>{"offers": null, "name": null}</script>`))
+		schema := &Schema{CssPath: []string{"script"}, Properties: []Schema{
+			{Id: "price", CssPath: []string{"$.offers[0].price"}, Selector: JSONPATH_SELECTOR},
+			{Id: "name", CssPath: []string{"$.name"}, Selector: JSONPATH_SELECTOR},
+		}}
+		So(schema.Validate(), ShouldBeNil)
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		So(rows[0].Errors, ShouldHaveLength, 2)
+		So(errors.Is(rows[0].Errors[0], ErrFieldNotFound), ShouldBeTrue)
+		So(errors.Is(rows[0].Errors[1], ErrFieldNotFound), ShouldBeTrue)
+		So(rows[0].Errors[1].Err.Error(), ShouldEqual, "field not found: $.name is null")
+	})
+	Convey("given a jsonpath object with a property which is not jsonpath", t, func() {
+		doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(selectorsPage))
+		schema := &Schema{CssPath: []string{"head"}, Properties: []Schema{
+			{Id: "product", CssPath: []string{"script"}, Type: OBJECT_PROPERTY, Properties: []Schema{
+				{Id: "offers", CssPath: []string{"$.offers"}, Selector: JSONPATH_SELECTOR, Type: OBJECT_PROPERTY, Properties: []Schema{
+					{Id: "price", CssPath: []string{".price"}, Type: STRING_PROPERTY},
+				}},
+			}},
+		}}
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		So(rows[0].Errors, ShouldHaveLength, 1)
+		So(rows[0].Errors[0].Id, ShouldEqual, "price")
+		So(errors.Is(rows[0].Errors[0], ErrInvalidSelector), ShouldBeTrue)
+	})
+}
+
+func TestSchema_ValidateSelectors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *Schema
+		paths  []string
+	}{
+		{name: "Test unknown selector", schema: &Schema{CssPath: []string{"body"}, Selector: "sql"}, paths: []string{"$.selector", "$.css[0]"}},
+		{name: "Test invalid xpath", schema: &Schema{CssPath: []string{"//div["}, Selector: XPATH_SELECTOR}, paths: []string{"$.css[0]"}},
+		{name: "Test root regex", schema: &Schema{CssPath: []string{"a+"}, Selector: REGEX_SELECTOR}, paths: []string{"$.selector"}},
+		{name: "Test css under jsonpath", schema: &Schema{CssPath: []string{"body"}, Properties: []Schema{
+			{Id: "data", CssPath: []string{"$.data"}, Selector: JSONPATH_SELECTOR, Type: OBJECT_PROPERTY, Properties: []Schema{
+				{Id: "name", CssPath: []string{".name"}},
+			}},
+		}}, paths: []string{"$.properties[0].properties[0].selector"}},
+		{name: "Test regex object", schema: &Schema{CssPath: []string{"body"}, Properties: []Schema{
+			{Id: "data", CssPath: []string{"(a)"}, Selector: REGEX_SELECTOR, Type: OBJECT_PROPERTY},
+		}}, paths: []string{"$.properties[0].selector"}},
+		{name: "Test empty jsonpath", schema: &Schema{CssPath: []string{"body"}, Properties: []Schema{
+			{Id: "data", CssPath: []string{""}, Selector: JSONPATH_SELECTOR},
+		}}, paths: []string{"$.properties[0].css[0]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs SchemaErrors
+			if !errors.As(tt.schema.Validate(), &errs) {
+				t.Fatalf("Validate() did not return SchemaErrors")
+			}
+			var paths []string
+			for _, err := range errs {
+				paths = append(paths, err.Path)
+				if !errors.Is(err, ErrInvalidSelector) {
+					t.Errorf("Validate() error %v is not ErrInvalidSelector", err)
+				}
+			}
+			if len(paths) != len(tt.paths) {
+				t.Fatalf("Validate() paths = %v, want %v", paths, tt.paths)
+			}
+			for i := range paths {
+				if paths[i] != tt.paths[i] {
+					t.Errorf("Validate() paths = %v, want %v", paths, tt.paths)
+				}
+			}
+		})
+	}
+}
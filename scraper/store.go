@@ -1 +1 @@
-package scraper
+package scraper
@@ -1,109 +1,196 @@
-package scraper
-
-import (
-	"runtime"
-	"time"
-
-	"github.com/olebedev/emitter"
-)
-
-// StreamRunner runs a stream of urls through a scraper
-type StreamRunner struct {
-	scraper  Scraper
-	inputURL chan string
-	emitter  *emitter.Emitter
-}
-
-func (s *StreamRunner) worker(id int, urls <-chan string) {
-	var done chan struct{}
-	for url := range urls {
-		res, err := s.scraper.ScrapeURL(url)
-		if err == nil {
-			rows, err := s.scraper.GetRows(res)
-			if err == nil {
-				done = s.emitter.Emit(url+":result", rows)
-				select {
-				case <-done:
-					// so the sending is done
-				case <-time.After(5):
-					// time is out, let's discard emitting
-					close(done)
-				}
-				continue
-			}
-		}
-		done = s.emitter.Emit(url+":error", err)
-		select {
-		case <-done:
-			// so the sending is done
-		case <-time.After(5):
-			// time is out, let's discard emitting
-			close(done)
-		}
-	}
-}
-
-func (s *StreamRunner) pool() *StreamRunner {
-	for w := 1; w <= runtime.NumCPU()-1; w++ {
-		go s.worker(w, s.inputURL)
-	}
-	return s
-}
-
-//Add a url to the stream
-func (s *StreamRunner) Add(url string) bool {
-	s.inputURL <- url
-	return true
-}
-
-//GetResult of a url
-func (s *StreamRunner) GetOneResult(url string) <-chan emitter.Event {
-	return s.emitter.Once(url + ":result")
-}
-
-//GetAllError of a url
-func (s *StreamRunner) GetAllError(url string) <-chan emitter.Event {
-	return s.emitter.Once(url + ":error")
-}
-
-//GetAllResult of a url
-func (s *StreamRunner) GetAllResult(url string) <-chan emitter.Event {
-	return s.emitter.Once(url + ":result")
-}
-
-//GetOneError of a url
-func (s *StreamRunner) GetOneError(url string) <-chan emitter.Event {
-	return s.emitter.Once(url + ":error")
-}
-
-// AResult waits and returns a result or an error if a scrape request failed
-func (s *StreamRunner) AResult(path string) (record map[string]interface{}, err error) {
-OUTER:
-	for {
-		select {
-		case ev := <-s.GetOneError(path):
-			err = ev.Args[0].(error)
-			break OUTER
-		case ev := <-s.GetOneResult(path):
-			vi := ev.Args[0].([]interface{})
-			record = vi[0].(map[string]interface{})
-			break OUTER
-		}
-	}
-	return
-}
-
-//Close runner
-func (s *StreamRunner) Close() {
-	close(s.inputURL)
-}
-
-// NewStreamRunner creates a pointer to a new stream runner
-func NewStreamRunner(scraper Scraper) *StreamRunner {
-	s := &StreamRunner{
-		scraper:  scraper,
-		inputURL: make(chan string),
-		emitter:  &emitter.Emitter{},
-	}
-	return s.pool()
-}
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/olebedev/emitter"
+)
+
+// StreamRunner runs a stream of urls through a scraper
+type StreamRunner struct {
+	scraper  Scraper
+	inputURL chan string
+	emitter  *emitter.Emitter
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func (s *StreamRunner) worker(id int, urls <-chan string) {
+	var (
+		done chan struct{}
+		url  string
+		ok   bool
+	)
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case url, ok = <-urls:
+			if !ok {
+				return
+			}
+		}
+		res, err := ScrapeURLContext(s.ctx, s.scraper, url)
+		if err == nil {
+			var rows []interface{}
+			rows, err = GetPageRowsContext(s.ctx, s.scraper, url, res)
+			if err == nil {
+				done = s.emitter.Emit(url+":result", rows)
+				select {
+				case <-done:
+					// so the sending is done
+				case <-time.After(5):
+					// time is out, let's discard emitting
+					close(done)
+				}
+				continue
+			}
+		}
+		done = s.emitter.Emit(url+":error", err)
+		select {
+		case <-done:
+			// so the sending is done
+		case <-time.After(5):
+			// time is out, let's discard emitting
+			close(done)
+		}
+	}
+}
+
+func (s *StreamRunner) pool() *StreamRunner {
+	for w := 1; w <= runtime.NumCPU()-1; w++ {
+		go s.worker(w, s.inputURL)
+	}
+	return s
+}
+
+//Add a url to the stream
+func (s *StreamRunner) Add(url string) bool {
+	return s.AddContext(context.Background(), url)
+}
+
+//AddContext adds a url to the stream, it returns false if ctx or the runner is done before a worker accepts the url
+func (s *StreamRunner) AddContext(ctx context.Context, url string) bool {
+	select {
+	case s.inputURL <- url:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+//GetResult of a url
+func (s *StreamRunner) GetOneResult(url string) <-chan emitter.Event {
+	return s.emitter.Once(url + ":result")
+}
+
+//GetAllError of a url
+func (s *StreamRunner) GetAllError(url string) <-chan emitter.Event {
+	return s.emitter.Once(url + ":error")
+}
+
+//GetAllResult of a url
+func (s *StreamRunner) GetAllResult(url string) <-chan emitter.Event {
+	return s.emitter.Once(url + ":result")
+}
+
+//GetOneError of a url
+func (s *StreamRunner) GetOneError(url string) <-chan emitter.Event {
+	return s.emitter.Once(url + ":error")
+}
+
+// AResult waits and returns a result or an error if a scrape request failed
+func (s *StreamRunner) AResult(path string) (record map[string]interface{}, err error) {
+	return s.AResultContext(context.Background(), path)
+}
+
+// AResultContext is like AResult but returns ctx.Err() if ctx is done before a result arrives
+func (s *StreamRunner) AResultContext(ctx context.Context, path string) (record map[string]interface{}, err error) {
+OUTER:
+	for {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break OUTER
+		case <-s.ctx.Done():
+			err = s.ctx.Err()
+			break OUTER
+		case ev := <-s.GetOneError(path):
+			err = ev.Args[0].(error)
+			break OUTER
+		case ev := <-s.GetOneResult(path):
+			vi, _ := ev.Args[0].([]interface{})
+			if len(vi) == 0 {
+				err = ErrNoRows
+				break OUTER
+			}
+			var ok bool
+			if record, ok = vi[0].(map[string]interface{}); !ok {
+				err = fmt.Errorf("%w: row is a %T, use AResultInto", ErrInvalidValue, vi[0])
+			}
+			break OUTER
+		}
+	}
+	return
+}
+
+// AResultInto waits for the result of path and decodes its first row into v, which must be a
+// pointer to a struct or to a value of the row type
+func (s *StreamRunner) AResultInto(ctx context.Context, path string, v interface{}) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	case ev := <-s.GetOneError(path):
+		err, _ := ev.Args[0].(error)
+		return err
+	case ev := <-s.GetOneResult(path):
+		vi, _ := ev.Args[0].([]interface{})
+		if len(vi) == 0 {
+			return ErrNoRows
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return fmt.Errorf("%w: %T is not a pointer", ErrInvalidValue, v)
+		}
+		if err := decodeValue(vi[0], rv.Elem()); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+//Close runner
+func (s *StreamRunner) Close() {
+	close(s.inputURL)
+}
+
+//Cancel stops all workers and aborts any scrape request in flight
+func (s *StreamRunner) Cancel() {
+	s.cancel()
+}
+
+// NewStreamRunner creates a pointer to a new stream runner
+func NewStreamRunner(scraper Scraper) *StreamRunner {
+	return NewStreamRunnerContext(context.Background(), scraper)
+}
+
+// NewStreamRunnerContext creates a pointer to a new stream runner whose workers stop when ctx is done
+func NewStreamRunnerContext(ctx context.Context, scraper Scraper) *StreamRunner {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &StreamRunner{
+		scraper:  scraper,
+		inputURL: make(chan string),
+		emitter:  &emitter.Emitter{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	return s.pool()
+}
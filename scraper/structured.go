@@ -0,0 +1,220 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// StructuredData is the schema.org and OpenGraph data a page publishes about itself
+type StructuredData struct {
+	// JSONLD holds every item of the application/ld+json scripts, items of a @graph are listed on their own
+	JSONLD []map[string]interface{} `json:"jsonld"`
+	// Microdata holds every top level itemscope with its properties, nested items are maps
+	Microdata []map[string]interface{} `json:"microdata"`
+	// OpenGraph maps the og:, article:, product: and twitter: meta properties to their content,
+	// repeated properties are lists
+	OpenGraph map[string]interface{} `json:"opengraph"`
+}
+
+// ExtractStructuredData finds the JSON-LD, microdata and OpenGraph data in node, relative links are
+// resolved against base. Scripts with invalid json are skipped and reported in the returned errors
+func ExtractStructuredData(node *goquery.Selection, base *url.URL) (*StructuredData, []error) {
+	items, errs := jsonLD(node)
+	return &StructuredData{
+		JSONLD:    items,
+		Microdata: microdata(node, base),
+		OpenGraph: openGraph(node, base),
+	}, errs
+}
+
+// jsonLD parses the application/ld+json scripts in node
+func jsonLD(node *goquery.Selection) (items []map[string]interface{}, errs []error) {
+	items = []map[string]interface{}{}
+	findAll(node, `script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &data); err != nil {
+			errs = append(errs, fmt.Errorf("%w: json-ld script %d: %v", ErrInvalidValue, i, err))
+			return
+		}
+		items = appendJSONLD(items, data)
+	})
+	return
+}
+
+// appendJSONLD appends the items of a json-ld document, arrays and @graph lists are flattened
+func appendJSONLD(items []map[string]interface{}, data interface{}) []map[string]interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			items = appendJSONLD(items, item)
+		}
+	case map[string]interface{}:
+		graph, ok := v["@graph"].([]interface{})
+		if !ok {
+			return append(items, v)
+		}
+		for _, item := range graph {
+			if m, ok := item.(map[string]interface{}); ok {
+				if _, ok := m["@context"]; !ok && v["@context"] != nil {
+					m["@context"] = v["@context"]
+				}
+			}
+			items = appendJSONLD(items, item)
+		}
+	}
+	return items
+}
+
+// microdata returns the top level items in node, items which are the property of another item are
+// part of that item instead
+func microdata(node *goquery.Selection, base *url.URL) []map[string]interface{} {
+	items := []map[string]interface{}{}
+	findAll(node, "[itemscope]").Each(func(i int, s *goquery.Selection) {
+		if _, ok := s.Attr("itemprop"); ok && s.ParentsFiltered("[itemscope]").Length() > 0 {
+			return
+		}
+		items = append(items, microdataItem(s, base))
+	})
+	return items
+}
+
+// microdataItem reads the type and properties of an itemscope in the shape of json-ld, the type
+// is the last part of the itemtype url and its vocabulary is the @context
+func microdataItem(scope *goquery.Selection, base *url.URL) map[string]interface{} {
+	item := map[string]interface{}{}
+	if types := strings.Fields(scope.AttrOr("itemtype", "")); len(types) > 0 {
+		i := strings.LastIndexAny(types[0], "/#")
+		item["@context"], item["@type"] = strings.TrimSuffix(types[0][:i+1], "/"), types[0][i+1:]
+	}
+	if id, ok := scope.Attr("itemid"); ok {
+		item["@id"] = id
+	}
+	scope.Find("[itemprop]").Each(func(i int, s *goquery.Selection) {
+		if s.ParentsFiltered("[itemscope]").First().Get(0) != scope.Get(0) {
+			return
+		}
+		var val interface{}
+		if _, ok := s.Attr("itemscope"); ok {
+			val = microdataItem(s, base)
+		} else {
+			val = microdataValue(s, base)
+		}
+		for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+			addValue(item, name, val)
+		}
+	})
+	return item
+}
+
+// microdataValue returns the value of an itemprop element which is not an item, it depends on the element
+func microdataValue(s *goquery.Selection, base *url.URL) interface{} {
+	attr := ""
+	switch goquery.NodeName(s) {
+	case "meta":
+		attr = "content"
+	case "a", "area", "link":
+		attr = "href"
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		if val, ok := s.Attr("datetime"); ok {
+			return val
+		}
+	}
+	if attr == "" {
+		return cleanText(s.Text())
+	}
+	val := s.AttrOr(attr, "")
+	if attr == "href" || attr == "src" || attr == "data" {
+		if link, err := resolveURL(base, val); err == nil {
+			return link
+		}
+	}
+	return val
+}
+
+var openGraphPrefixes = []string{"og:", "article:", "book:", "profile:", "product:", "music:", "video:", "twitter:"}
+
+// openGraph returns the OpenGraph meta properties in node, twitter cards use name instead of property
+func openGraph(node *goquery.Selection, base *url.URL) map[string]interface{} {
+	data := map[string]interface{}{}
+	findAll(node, "meta[property], meta[name]").Each(func(i int, s *goquery.Selection) {
+		name := s.AttrOr("property", s.AttrOr("name", ""))
+		content, ok := s.Attr("content")
+		if !ok || !isOpenGraph(name) {
+			return
+		}
+		if isOpenGraphURL(name) {
+			if link, err := resolveURL(base, content); err == nil {
+				content = link
+			}
+		}
+		addValue(data, name, content)
+	})
+	return data
+}
+
+func isOpenGraph(name string) bool {
+	for _, prefix := range openGraphPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpenGraphURL reports whether an OpenGraph property holds a link
+func isOpenGraphURL(name string) bool {
+	switch name {
+	case "og:url", "og:image", "og:video", "og:audio", "twitter:image":
+		return true
+	}
+	return strings.HasSuffix(name, ":url") || strings.HasSuffix(name, ":secure_url")
+}
+
+// findAll is like Find but also matches the nodes of node themselves
+func findAll(node *goquery.Selection, selector string) *goquery.Selection {
+	return node.Filter(selector).AddSelection(node.Find(selector))
+}
+
+// addValue sets data[key] to val, a key which is already set becomes a list of its values
+func addValue(data map[string]interface{}, key string, val interface{}) {
+	switch existing := data[key].(type) {
+	case nil:
+		data[key] = val
+	case []interface{}:
+		data[key] = append(existing, val)
+	default:
+		data[key] = []interface{}{existing, val}
+	}
+}
+
+// structuredValue extracts the structured data of a jsonld, microdata, opengraph or structured property from node
+func (e *Extractor) structuredValue(node *goquery.Selection, property *Schema, index int) (interface{}, ExtractErrors) {
+	base := documentBase(e.baseURL, node)
+	switch property.Type {
+	case MICRODATA_PROPERTY:
+		return microdata(node, base), nil
+	case OPENGRAPH_PROPERTY:
+		return openGraph(node, base), nil
+	}
+	var (
+		data, errs  = ExtractStructuredData(node, base)
+		extractErrs ExtractErrors
+	)
+	for _, err := range errs {
+		extractErrs = append(extractErrs, &ExtractError{Id: property.Id, CssPath: property.CssPath, Row: index, Err: err})
+	}
+	if property.Type == JSONLD_PROPERTY {
+		return data.JSONLD, extractErrs
+	}
+	return map[string]interface{}{"jsonld": data.JSONLD, "microdata": data.Microdata, "opengraph": data.OpenGraph}, extractErrs
+}
@@ -0,0 +1,130 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var structuredPage = `<html>
+<head>
+<meta property="og:title" content="Desk Lamp">
+<meta property="og:image" content="/img/lamp.png">
+<meta property="og:image" content="/img/lamp-side.png">
+<meta name="twitter:card" content="summary">
+<meta name="description" content="not open graph">
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+{"@type": "Product", "name": "Desk Lamp"}, {"@type": "BreadcrumbList"}]}</script>
+<script type="application/ld+json">{"@type": "Organization", "name": "Lamps Inc"}</script>
+<script type="application/ld+json">{broken</script>
+</head>
+<body>
+<div itemscope itemtype="https://schema.org/Product">
+<span itemprop="name">Desk Lamp</span>
+<img itemprop="image" src="img/lamp.png">
+<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+<meta itemprop="priceCurrency" content="EUR">
+<span itemprop="price">19.99</span>
+<link itemprop="availability" href="https://schema.org/InStock">
+</div>
+<span itemprop="category keywords">Lighting</span>
+</div>
+<div itemscope><time itemprop="date" datetime="2024-03-05">March 5</time></div>
+</body>
+</html>`
+
+func TestExtractStructuredData(t *testing.T) {
+	Convey("given a page with json-ld, microdata and open graph data", t, func() {
+		doc, err := goquery.NewDocumentFromReader(bytes.NewBufferString(structuredPage))
+		So(err, ShouldBeNil)
+		base, _ := url.Parse("http://example.com/shop/lamp")
+		data, errs := ExtractStructuredData(doc.Selection, base)
+		Convey("invalid json-ld scripts are reported", func() {
+			So(errs, ShouldHaveLength, 1)
+			So(errors.Is(errs[0], ErrInvalidValue), ShouldBeTrue)
+		})
+		Convey("json-ld graphs are flattened", func() {
+			So(data.JSONLD, ShouldResemble, []map[string]interface{}{
+				{"@context": "https://schema.org", "@type": "Product", "name": "Desk Lamp"},
+				{"@context": "https://schema.org", "@type": "BreadcrumbList"},
+				{"@type": "Organization", "name": "Lamps Inc"},
+			})
+		})
+		Convey("microdata items are read with their nested items", func() {
+			So(data.Microdata, ShouldResemble, []map[string]interface{}{
+				{
+					"@context": "https://schema.org",
+					"@type":    "Product",
+					"name":     "Desk Lamp",
+					"image":    "http://example.com/shop/img/lamp.png",
+					"offers": map[string]interface{}{
+						"@context":      "https://schema.org",
+						"@type":         "Offer",
+						"priceCurrency": "EUR",
+						"price":         "19.99",
+						"availability":  "https://schema.org/InStock",
+					},
+					"category": "Lighting",
+					"keywords": "Lighting",
+				},
+				{"date": "2024-03-05"},
+			})
+		})
+		Convey("open graph properties are collected", func() {
+			So(data.OpenGraph, ShouldResemble, map[string]interface{}{
+				"og:title":     "Desk Lamp",
+				"og:image":     []interface{}{"http://example.com/img/lamp.png", "http://example.com/img/lamp-side.png"},
+				"twitter:card": "summary",
+			})
+		})
+	})
+}
+
+func TestExtractor_StructuredProperties(t *testing.T) {
+	Convey("given a schema with structured data properties", t, func() {
+		doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(structuredPage))
+		schema := &Schema{CssPath: []string{"html"}, Properties: []Schema{
+			{Id: "title", CssPath: []string{"span[itemprop=name]"}, Type: STRING_PROPERTY},
+			{Id: "ld", CssPath: []string{"head"}, Type: JSONLD_PROPERTY},
+			{Id: "og", CssPath: []string{""}, Type: OPENGRAPH_PROPERTY},
+			{Id: "product", CssPath: []string{"[itemtype$=Product]"}, Type: MICRODATA_PROPERTY},
+		}}
+		So(schema.Validate(), ShouldBeNil)
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows, ShouldHaveLength, 1)
+		Convey("they sit alongside the css properties", func() {
+			So(rows[0].Data["title"], ShouldEqual, "Desk Lamp")
+			So(rows[0].Data["ld"], ShouldHaveLength, 3)
+			So(rows[0].Data["og"], ShouldContainKey, "og:title")
+			product := rows[0].Data["product"].([]map[string]interface{})
+			So(product, ShouldHaveLength, 1)
+			So(product[0]["@type"], ShouldEqual, "Product")
+		})
+		Convey("invalid json-ld is reported as a row error", func() {
+			So(rows[0].Errors, ShouldHaveLength, 1)
+			So(rows[0].Errors[0].Id, ShouldEqual, "ld")
+		})
+	})
+	Convey("given a structured property with a regex selector", t, func() {
+		schema := &Schema{CssPath: []string{"html"}, Properties: []Schema{
+			{Id: "ld", CssPath: []string{"(.*)"}, Selector: REGEX_SELECTOR, Type: STRUCTURED_PROPERTY},
+		}}
+		So(errors.Is(schema.Validate(), ErrInvalidSelector), ShouldBeTrue)
+	})
+}
+
+func TestPageScraper_StructuredData(t *testing.T) {
+	Convey("given a page scraper without a schema", t, func() {
+		p := NewPageScraper(nil, nil)
+		data, err := p.StructuredData("http://example.com/shop/lamp", []byte(structuredPage))
+		So(err, ShouldBeNil)
+		So(data.JSONLD, ShouldHaveLength, 3)
+		So(data.Microdata, ShouldHaveLength, 2)
+		So(data.OpenGraph["og:title"], ShouldEqual, "Desk Lamp")
+	})
+}
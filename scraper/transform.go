@@ -0,0 +1,266 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	dry "github.com/ungerik/go-dry"
+)
+
+// TransformFunc transforms a value of a schema property, arg is the argument of the transform in the
+// schema or nil. A transform should return an error wrapping ErrInvalidTransform when arg can not be used
+type TransformFunc func(val interface{}, arg interface{}) (interface{}, error)
+
+// TransformStep is an item of the transforms list of a schema. It is written as the name of a
+// transform, "trim", or as an object with the name and argument of a transform, {"replace": [",", ""]}
+type TransformStep struct {
+	Name string
+	Arg  interface{}
+}
+
+func (t *TransformStep) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.Name); err == nil {
+		t.Arg = nil
+		return nil
+	}
+	var step map[string]interface{}
+	if err := json.Unmarshal(data, &step); err != nil {
+		return fmt.Errorf("%w: a transform is a name or an object with one name", ErrInvalidTransform)
+	}
+	if len(step) != 1 {
+		return fmt.Errorf("%w: a transform object must have exactly one name, it has %d", ErrInvalidTransform, len(step))
+	}
+	for name, arg := range step {
+		t.Name, t.Arg = name, arg
+	}
+	return nil
+}
+
+func (t TransformStep) MarshalJSON() ([]byte, error) {
+	if t.Arg == nil {
+		return json.Marshal(t.Name)
+	}
+	return json.Marshal(map[string]interface{}{t.Name: t.Arg})
+}
+
+// defaultKeyTransforms normalize the keys of a property_array which has no key transforms
+var defaultKeyTransforms = []TransformStep{{Name: "clean"}, {Name: "key"}}
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]TransformFunc{
+		"trim":    stringTransform(trimTransform),
+		"lower":   stringTransform(func(s string, arg interface{}) (interface{}, error) { return strings.ToLower(s), nil }),
+		"upper":   stringTransform(func(s string, arg interface{}) (interface{}, error) { return strings.ToUpper(s), nil }),
+		"clean":   stringTransform(func(s string, arg interface{}) (interface{}, error) { return removeInvalidUtf(stringMinifier(s)), nil }),
+		"key":     stringTransform(keyTransform),
+		"replace": stringTransform(replaceTransform),
+		"regex":   stringTransform(regexTransform),
+		"split":   stringTransform(splitTransform),
+		"join":    joinTransform,
+	}
+)
+
+// RegisterTransform adds a named transform which can be used in the transforms of every schema,
+// it replaces a transform with the same name
+func RegisterTransform(name string, fn TransformFunc) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = fn
+}
+
+// TransformNames returns the names of the registered transforms, sorted
+func TransformNames() []string {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupTransform(name string) (TransformFunc, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	fn, ok := transforms[name]
+	return fn, ok
+}
+
+// applyTransforms runs the steps in order, each with the value returned by the previous one
+func applyTransforms(steps []TransformStep, val interface{}) (interface{}, error) {
+	for i, step := range steps {
+		fn, ok := lookupTransform(step.Name)
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrInvalidTransform, step.Name)
+		}
+		var err error
+		if val, err = fn(val, step.Arg); err != nil {
+			return nil, fmt.Errorf("transforms[%d] %s: %w", i, step.Name, err)
+		}
+	}
+	return val, nil
+}
+
+// validateTransforms checks that every step is registered and that its argument can be used
+func validateTransforms(path string, steps []TransformStep) (errs SchemaErrors) {
+	for i, step := range steps {
+		fn, ok := lookupTransform(step.Name)
+		if !ok {
+			errs = append(errs, &SchemaError{Path: fmt.Sprintf("%s[%d]", path, i), Err: fmt.Errorf("%w %q", ErrInvalidTransform, step.Name)})
+			continue
+		}
+		if _, err := fn("", step.Arg); errors.Is(err, ErrInvalidTransform) {
+			errs = append(errs, &SchemaError{Path: fmt.Sprintf("%s[%d]", path, i), Err: err})
+		}
+	}
+	return
+}
+
+// stringTransform makes a TransformFunc from a transform of strings, lists are transformed item by
+// item and lists returned for an item are flattened into the result
+func stringTransform(fn func(s string, arg interface{}) (interface{}, error)) TransformFunc {
+	var transform TransformFunc
+	transform = func(val interface{}, arg interface{}) (interface{}, error) {
+		switch v := val.(type) {
+		case string:
+			return fn(v, arg)
+		case []string:
+			items := make([]interface{}, len(v))
+			for i, s := range v {
+				items[i] = s
+			}
+			return transform(items, arg)
+		case []interface{}:
+			var result []string
+			for _, item := range v {
+				t, err := transform(item, arg)
+				if err != nil {
+					return nil, err
+				}
+				switch t := t.(type) {
+				case []string:
+					result = append(result, t...)
+				case string:
+					result = append(result, t)
+				default:
+					return nil, fmt.Errorf("%w: %T is not a string", ErrInvalidValue, t)
+				}
+			}
+			return result, nil
+		case nil:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %T is not a string", ErrInvalidValue, val)
+	}
+	return transform
+}
+
+// stringArg returns arg as a string, def is used when arg is nil
+func stringArg(arg interface{}, def string) (string, error) {
+	switch a := arg.(type) {
+	case nil:
+		return def, nil
+	case string:
+		return a, nil
+	}
+	return "", fmt.Errorf("%w: argument %v must be a string", ErrInvalidTransform, arg)
+}
+
+// trimTransform trims space, or the characters of arg
+func trimTransform(s string, arg interface{}) (interface{}, error) {
+	cutset, err := stringArg(arg, "")
+	if err != nil {
+		return nil, err
+	}
+	if cutset == "" {
+		return strings.TrimSpace(s), nil
+	}
+	return strings.Trim(s, cutset), nil
+}
+
+// keyTransform normalizes a label like "Screen Size:" to a key like "screen_size"
+func keyTransform(s string, arg interface{}) (interface{}, error) {
+	return strings.ToLower(strings.Trim(dry.StringReplaceMulti(s, ",", "", ".", "", ":", " ", " ", "_"), "_")), nil
+}
+
+// replaceTransform replaces every old string with its new string, arg is a list of old, new pairs
+func replaceTransform(s string, arg interface{}) (interface{}, error) {
+	pairs, ok := arg.([]interface{})
+	if !ok || len(pairs) == 0 || len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("%w: replace needs a list of old, new pairs", ErrInvalidTransform)
+	}
+	oldnew := make([]string, len(pairs))
+	for i, p := range pairs {
+		if oldnew[i], ok = p.(string); !ok {
+			return nil, fmt.Errorf("%w: replace argument %v must be a string", ErrInvalidTransform, p)
+		}
+	}
+	return strings.NewReplacer(oldnew...).Replace(s), nil
+}
+
+var regexCache sync.Map
+
+// regexTransform returns the first group of the regular expression arg in s or the whole match
+// when it has no group
+func regexTransform(s string, arg interface{}) (interface{}, error) {
+	pattern, err := stringArg(arg, "")
+	if err != nil {
+		return nil, err
+	}
+	var re *regexp.Regexp
+	if cached, ok := regexCache.Load(pattern); ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		if re, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTransform, err)
+		}
+		regexCache.Store(pattern, re)
+	}
+	m := re.FindStringSubmatch(s)
+	switch {
+	case m == nil:
+		return nil, fmt.Errorf("%w: %q does not match %q", ErrInvalidValue, s, pattern)
+	case len(m) > 1:
+		return m[1], nil
+	}
+	return m[0], nil
+}
+
+// splitTransform splits s around arg, a comma by default
+func splitTransform(s string, arg interface{}) (interface{}, error) {
+	sep, err := stringArg(arg, ",")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(s, sep), nil
+}
+
+// joinTransform joins a list with arg, a space by default
+func joinTransform(val interface{}, arg interface{}) (interface{}, error) {
+	sep, err := stringArg(arg, " ")
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case []string:
+		return strings.Join(v, sep), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, sep), nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w: %T is not a list", ErrInvalidValue, val)
+}
@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_applyTransforms(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   string
+		val     interface{}
+		want    interface{}
+		wantErr error
+	}{
+		{name: "Test trim and lower", steps: `["trim", "lower"]`, val: "  Desk LAMP ", want: "desk lamp"},
+		{name: "Test trim cutset", steps: `[{"trim": "*"}]`, val: "**new**", want: "new"},
+		{name: "Test replace", steps: `[{"replace": [",", "", "$", ""]}]`, val: "$1,234", want: "1234"},
+		{name: "Test regex group", steps: `[{"regex": "(\\d+) left"}]`, val: "only 3 left", want: "3"},
+		{name: "Test regex without match", steps: `[{"regex": "\\d+"}]`, val: "none", wantErr: ErrInvalidValue},
+		{name: "Test split then trim", steps: `[{"split": "|"}, "trim"]`, val: "red | green|blue ", want: []string{"red", "green", "blue"}},
+		{name: "Test join", steps: `[{"join": ", "}]`, val: []string{"a", "b"}, want: "a, b"},
+		{name: "Test list items", steps: `["upper"]`, val: []interface{}{"a", "b"}, want: []string{"A", "B"}},
+		{name: "Test clean", steps: `["clean"]`, val: " a \n\t b ", want: "a b"},
+		{name: "Test key", steps: `["clean", "key"]`, val: "Screen Size:", want: "screen_size"},
+		{name: "Test unknown transform", steps: `["reverse"]`, val: "a", wantErr: ErrInvalidTransform},
+		{name: "Test invalid argument", steps: `[{"replace": ["a"]}]`, val: "a", wantErr: ErrInvalidTransform},
+		{name: "Test string transform of a map", steps: `["lower"]`, val: map[string]interface{}{}, wantErr: ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var steps []TransformStep
+			if err := json.Unmarshal([]byte(tt.steps), &steps); err != nil {
+				t.Fatal(err)
+			}
+			got, err := applyTransforms(steps, tt.val)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("applyTransforms() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyTransforms() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyTransforms() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformStep_JSON(t *testing.T) {
+	Convey("given a transforms list", t, func() {
+		data := `["trim",{"replace":[",",""]}]`
+		var steps []TransformStep
+		So(json.Unmarshal([]byte(data), &steps), ShouldBeNil)
+		So(steps, ShouldResemble, []TransformStep{{Name: "trim"}, {Name: "replace", Arg: []interface{}{",", ""}}})
+		out, err := json.Marshal(steps)
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, data)
+	})
+	Convey("given a transform object with two names", t, func() {
+		var step TransformStep
+		err := json.Unmarshal([]byte(`{"trim": null, "lower": null}`), &step)
+		So(errors.Is(err, ErrInvalidTransform), ShouldBeTrue)
+	})
+}
+
+var transformPage = `<html><body>
+<div class="item">
+<h2>  Desk   LAMP </h2>
+<span class="price">Price: 1.234,50 EUR</span>
+<span class="tags">red|green | blue</span>
+<ul><li>Screen Size: <b> 15" </b></li><li>WEIGHT (kg) <b>2</b></li></ul>
+</div>
+</body></html>`
+
+func TestExtractor_Transforms(t *testing.T) {
+	RegisterTransform("reverse", func(val interface{}, arg interface{}) (interface{}, error) {
+		s, _ := val.(string)
+		runes := []rune(s)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	})
+	doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(transformPage))
+	Convey("given a schema with transforms", t, func() {
+		schema := SchemaFromString(`{"css": [".item"], "properties": [
+			{"id": "raw", "css": ["h2"], "type": "string", "transforms": ["trim"]},
+			{"id": "name", "css": ["h2"], "type": "string", "transforms": ["clean", "lower", "reverse"]},
+			{"id": "price", "css": [".price"], "type": "float", "locale": "de", "transforms": [{"regex": "([\\d.,]+)"}]},
+			{"id": "tags", "css": [".tags"], "type": "array", "transforms": [{"split": "|"}, "trim"]},
+			{"id": "specs", "css": ["li"], "type": "property_array", "key": [""], "val": ["b"],
+				"keyTransforms": ["clean", {"regex": "^(\\w+)"}, "lower"], "transforms": ["trim"]},
+			{"id": "default_keys", "css": ["li"], "type": "property_array", "key": [""], "val": ["b"]}
+		]}`)
+		So(schema, ShouldNotBeNil)
+		So(schema.Validate(), ShouldBeNil)
+		rows, err := NewExtractor(schema).ExtractRows(doc.Selection)
+		So(err, ShouldBeNil)
+		So(rows[0].Errors, ShouldBeEmpty)
+		data := rows[0].Data
+		So(data["raw"], ShouldEqual, "Desk   LAMP")
+		So(data["name"], ShouldEqual, "pmal ksed")
+		So(data["price"], ShouldEqual, 1234.5)
+		So(data["tags"], ShouldResemble, []string{"red", "green", "blue"})
+		So(data["specs"], ShouldResemble, map[string]interface{}{"screen": `15"`, "weight": "2"})
+		So(data["default_keys"], ShouldResemble, map[string]interface{}{"screen_size": `15"`, "weight_(kg)": "2"})
+	})
+	Convey("given a schema with invalid transforms", t, func() {
+		schema := &Schema{CssPath: []string{"body"}, Properties: []Schema{
+			{Id: "name", CssPath: []string{"h2"}, Transforms: []TransformStep{{Name: "nope"}, {Name: "regex", Arg: "("}}},
+		}}
+		err := schema.Validate()
+		So(errors.Is(err, ErrInvalidTransform), ShouldBeTrue)
+		So(strings.Count(err.Error(), "$.properties[0].transforms["), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,378 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Money is the value of a currency property
+type Money struct {
+	Amount json.Number `json:"amount"`
+	Code   string      `json:"code"`
+}
+
+// valueFormat holds the schema options used to parse a value
+type valueFormat struct {
+	format   string
+	locale   string
+	currency string
+}
+
+func (s *Schema) valueFormat() valueFormat {
+	return valueFormat{format: s.Format, locale: s.Locale, currency: s.Currency}
+}
+
+func (s *ParserSchema) valueFormat() valueFormat {
+	return valueFormat{format: s.Format, locale: s.Locale, currency: s.Currency}
+}
+
+// parseValue converts val to the go value of typ, strings are parsed and values which already
+// have the right type are returned as is. Relative urls are resolved against base
+func parseValue(typ string, f valueFormat, val interface{}, base *url.URL) (interface{}, error) {
+	if s, ok := val.(string); ok {
+		return parseString(typ, f, s, base)
+	}
+	switch typ {
+	case INT_PROPERTY:
+		switch v := val.(type) {
+		case int:
+			return v, nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int(v), nil
+			}
+		}
+	case FLOAT_PROPERTY:
+		switch v := val.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		}
+	case DECIMAL_PROPERTY:
+		switch v := val.(type) {
+		case json.Number:
+			return v, nil
+		case float64:
+			return json.Number(strconv.FormatFloat(v, 'f', -1, 64)), nil
+		case int:
+			return json.Number(strconv.Itoa(v)), nil
+		}
+	case BOOL_PROPERTY:
+		if v, ok := val.(bool); ok {
+			return v, nil
+		}
+	case DATETIME_PROPERTY:
+		if v, ok := val.(time.Time); ok {
+			return v, nil
+		}
+	case CURRENCY_PROPERTY:
+		switch v := val.(type) {
+		case Money:
+			return v, nil
+		case float64, int:
+			if f.currency == "" {
+				return nil, fmt.Errorf("%w: %v has no currency code", ErrInvalidValue, v)
+			}
+			return Money{Amount: json.Number(fmt.Sprint(v)), Code: strings.ToUpper(f.currency)}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %T can not be converted to %s", ErrInvalidValue, val, typ)
+}
+
+func parseString(typ string, f valueFormat, s string, base *url.URL) (interface{}, error) {
+	s = strings.TrimSpace(s)
+	switch typ {
+	case INT_PROPERTY:
+		n, err := parseNumber(s, f.locale)
+		if err != nil {
+			return nil, err
+		}
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not an integer", ErrInvalidValue, s)
+		}
+		return i, nil
+	case FLOAT_PROPERTY:
+		n, err := parseNumber(s, f.locale)
+		if err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidValue, err)
+		}
+		return v, nil
+	case DECIMAL_PROPERTY:
+		n, err := parseNumber(s, f.locale)
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(n), nil
+	case BOOL_PROPERTY:
+		switch strings.ToLower(s) {
+		case "true", "yes", "y", "1", "on":
+			return true, nil
+		case "false", "no", "n", "0", "off":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%w: %q is not a boolean", ErrInvalidValue, s)
+	case DATETIME_PROPERTY:
+		return parseDatetime(s, f)
+	case CURRENCY_PROPERTY:
+		return parseMoney(s, f)
+	case URL_PROPERTY:
+		return parseURL(s, base)
+	}
+	return s, nil
+}
+
+// numberPatterns match a number by its decimal separator, a grouping separator must be followed by
+// a complete group of three digits so "4.5, 120 reviews" is 4.5 and not 4.5120
+var numberPatterns = map[string]*regexp.Regexp{
+	".": regexp.MustCompile(`[-+]?(?:\d{1,3}(?:[,' \x{00a0}\x{202f}]\d{3})+|\d+)(?:\.\d+)?`),
+	",": regexp.MustCompile(`[-+]?(?:\d{1,3}(?:[.' \x{00a0}\x{202f}]\d{3})+|\d+)(?:,\d+)?`),
+}
+
+// decimalCommaLocales are the languages which write decimals with a comma
+var decimalCommaLocales = map[string]bool{
+	"de": true, "fr": true, "es": true, "it": true, "pt": true, "nl": true, "ru": true, "pl": true,
+	"tr": true, "sv": true, "da": true, "nb": true, "no": true, "fi": true, "cs": true, "id": true,
+}
+
+// language returns the language of a locale like "de-DE" or "pt_BR"
+func language(locale string) string {
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.ToLower(parts[0])
+}
+
+// numberIndex returns the location of the first number of s as FindStringIndex does and the decimal
+// separator of locale
+func numberIndex(s, locale string) ([]int, string) {
+	decimal := "."
+	if decimalCommaLocales[language(locale)] {
+		decimal = ","
+	}
+	return numberPatterns[decimal].FindStringIndex(s), decimal
+}
+
+// parseNumber finds the first number in s and normalizes it to a plain decimal like "-1234.5",
+// grouping separators are removed and the decimal separator depends on locale. A number which could
+// be read in more than one way, like "1,5" in english or "1,234 567", is an error
+func parseNumber(s, locale string) (string, error) {
+	loc, decimal := numberIndex(s, locale)
+	if loc == nil {
+		return "", fmt.Errorf("%w: %q is not a number", ErrInvalidValue, s)
+	}
+	m, rest := s[loc[0]:loc[1]], s[loc[1]:]
+	if len(rest) > 0 && (isDigit(rest[0]) || strings.IndexByte(".,'", rest[0]) >= 0 && len(rest) > 1 && isDigit(rest[1])) {
+		return "", fmt.Errorf("%w: %q is an ambiguous number", ErrInvalidValue, s)
+	}
+	var (
+		digits strings.Builder
+		group  rune
+	)
+	for i, r := range m {
+		switch {
+		case r >= '0' && r <= '9', r == '-':
+			digits.WriteRune(r)
+		case r == '+':
+		case strings.HasPrefix(m[i:], decimal):
+			digits.WriteByte('.')
+		default:
+			if group != 0 && r != group {
+				return "", fmt.Errorf("%w: %q is an ambiguous number", ErrInvalidValue, s)
+			}
+			group = r
+		}
+	}
+	return digits.String(), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// currencySymbols maps currency symbols to their ISO 4217 code, "$" is taken to be US dollars
+var currencySymbols = []struct{ symbol, code string }{
+	{"US$", "USD"}, {"C$", "CAD"}, {"A$", "AUD"}, {"R$", "BRL"}, {"$", "USD"},
+	{"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"}, {"₦", "NGN"}, {"₹", "INR"}, {"₩", "KRW"}, {"₽", "RUB"}, {"₺", "TRY"},
+}
+
+// isoCurrencies are the ISO 4217 currency codes, other words of three capitals are not currencies
+var isoCurrencies = map[string]bool{}
+
+func init() {
+	for _, code := range strings.Fields(`AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB
+		BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP
+		GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW
+		KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN
+		NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS
+		SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD
+		XOF XPF YER ZAR ZMW ZWL`) {
+		isoCurrencies[code] = true
+	}
+}
+
+var currencyCodePattern = regexp.MustCompile(`\b[A-Z]{3}\b`)
+
+// currencyCode returns the currency of s, a symbol or code written next to the amount at loc comes
+// first, then any code and then any symbol in s
+func currencyCode(s string, loc []int) string {
+	before, after := strings.TrimSpace(s[:loc[0]]), strings.TrimSpace(s[loc[1]:])
+	for _, c := range currencySymbols {
+		if strings.HasSuffix(before, c.symbol) || strings.HasPrefix(after, c.symbol) {
+			return c.code
+		}
+	}
+	if code := currencyCodePattern.FindString(after); code != "" && strings.HasPrefix(after, code) && isoCurrencies[code] {
+		return code
+	}
+	if codes := currencyCodePattern.FindAllString(before, -1); len(codes) > 0 {
+		if code := codes[len(codes)-1]; strings.HasSuffix(before, code) && isoCurrencies[code] {
+			return code
+		}
+	}
+	for _, code := range currencyCodePattern.FindAllString(s, -1) {
+		if isoCurrencies[code] {
+			return code
+		}
+	}
+	for _, c := range currencySymbols {
+		if strings.Contains(s, c.symbol) {
+			return c.code
+		}
+	}
+	return ""
+}
+
+func parseMoney(s string, f valueFormat) (interface{}, error) {
+	amount, err := parseNumber(s, f.locale)
+	if err != nil {
+		return nil, err
+	}
+	loc, _ := numberIndex(s, f.locale)
+	code := currencyCode(s, loc)
+	if code == "" {
+		code = strings.ToUpper(f.currency)
+	}
+	if code == "" {
+		return nil, fmt.Errorf("%w: %q has no currency code", ErrInvalidValue, s)
+	}
+	return Money{Amount: json.Number(amount), Code: code}, nil
+}
+
+// datetimeLayouts are tried in order when a datetime property has no format
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+}
+
+// monthNames are the month names of the supported locales, full names first
+var monthNames = map[string][]string{
+	"de": {"januar", "februar", "märz", "april", "mai", "juni", "juli", "august", "september", "oktober", "november", "dezember",
+		"jan", "feb", "mär", "apr", "mai", "jun", "jul", "aug", "sep", "okt", "nov", "dez"},
+	"fr": {"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre",
+		"janv", "févr", "mars", "avr", "mai", "juin", "juil", "août", "sept", "oct", "nov", "déc"},
+	"es": {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre",
+		"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sep", "oct", "nov", "dic"},
+	"it": {"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre",
+		"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+	"pt": {"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro",
+		"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+	"nl": {"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december",
+		"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+}
+
+var (
+	monthPatternsMu sync.Mutex
+	monthPatterns   = map[string]*regexp.Regexp{}
+)
+
+// englishMonths replaces the month names of locale in s with english ones so time.Parse can read them,
+// abbreviations are replaced with english abbreviations
+func englishMonths(s, locale string) string {
+	lang := language(locale)
+	names, ok := monthNames[lang]
+	if !ok {
+		return s
+	}
+	monthPatternsMu.Lock()
+	re, ok := monthPatterns[lang]
+	if !ok {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = regexp.QuoteMeta(name)
+		}
+		re = regexp.MustCompile(`(?i)(^|[^\pL])(` + strings.Join(quoted, "|") + `)\.?($|[^\pL])`)
+		monthPatterns[lang] = re
+	}
+	monthPatternsMu.Unlock()
+	return re.ReplaceAllStringFunc(s, func(m string) string {
+		sub := re.FindStringSubmatch(m)
+		for i, name := range names {
+			if strings.EqualFold(name, sub[2]) {
+				month := time.Month(i%12 + 1).String()
+				if i >= 12 {
+					month = month[:3]
+				}
+				return sub[1] + month + sub[3]
+			}
+		}
+		return m
+	})
+}
+
+func parseDatetime(s string, f valueFormat) (interface{}, error) {
+	if f.locale != "" {
+		s = englishMonths(s, f.locale)
+	}
+	layouts := datetimeLayouts
+	if f.format != "" {
+		layouts = []string{f.format}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if f.format != "" {
+		return nil, fmt.Errorf("%w: %q does not match %q", ErrInvalidValue, s, f.format)
+	}
+	return nil, fmt.Errorf("%w: %q is not a known datetime format", ErrInvalidValue, s)
+}
+
+func parseURL(s string, base *url.URL) (interface{}, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("%w: %q is a relative url", ErrInvalidValue, s)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_parseValue(t *testing.T) {
+	base, _ := url.Parse("http://example.com/shop/list?page=2")
+	tests := []struct {
+		name    string
+		typ     string
+		format  valueFormat
+		val     interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "Test integer with grouping", typ: INT_PROPERTY, val: "1,234 items", want: 1234},
+		{name: "Test integer from float", typ: INT_PROPERTY, val: float64(7), want: 7},
+		{name: "Test invalid integer", typ: INT_PROPERTY, val: "none", wantErr: true},
+		{name: "Test fractional integer", typ: INT_PROPERTY, val: "4.5", wantErr: true},
+		{name: "Test float", typ: FLOAT_PROPERTY, val: "4.5 out of 5", want: 4.5},
+		{name: "Test float with locale", typ: FLOAT_PROPERTY, format: valueFormat{locale: "de-DE"}, val: "1.234,5", want: 1234.5},
+		{name: "Test rating followed by a count", typ: FLOAT_PROPERTY, val: "4.5, 120 reviews", want: 4.5},
+		{name: "Test rating with locale followed by a count", typ: FLOAT_PROPERTY, format: valueFormat{locale: "de"}, val: "4,5, 120 Bewertungen", want: 4.5},
+		{name: "Test first of a list of integers", typ: INT_PROPERTY, val: "Sizes 10, 12", want: 10},
+		{name: "Test ambiguous decimal comma", typ: FLOAT_PROPERTY, val: "1,5", wantErr: true},
+		{name: "Test incomplete digit group", typ: INT_PROPERTY, val: "1,2345", wantErr: true},
+		{name: "Test mixed grouping separators", typ: INT_PROPERTY, val: "1,234 567", wantErr: true},
+		{name: "Test negative float", typ: FLOAT_PROPERTY, val: "-0.25", want: -0.25},
+		{name: "Test invalid float", typ: FLOAT_PROPERTY, val: "n/a", wantErr: true},
+		{name: "Test decimal", typ: DECIMAL_PROPERTY, val: "$1,234.50", want: json.Number("1234.50")},
+		{name: "Test decimal from float", typ: DECIMAL_PROPERTY, val: 0.1, want: json.Number("0.1")},
+		{name: "Test bool", typ: BOOL_PROPERTY, val: "Yes", want: true},
+		{name: "Test bool false", typ: BOOL_PROPERTY, val: "off", want: false},
+		{name: "Test invalid bool", typ: BOOL_PROPERTY, val: "maybe", wantErr: true},
+		{name: "Test datetime", typ: DATETIME_PROPERTY, val: "2024-03-05", want: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "Test datetime with format", typ: DATETIME_PROPERTY, format: valueFormat{format: "02/01/2006 15:04"}, val: "05/03/2024 10:30", want: time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)},
+		{name: "Test datetime with locale", typ: DATETIME_PROPERTY, format: valueFormat{format: "2 January 2006", locale: "fr"}, val: "5 mars 2024", want: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "Test datetime with abbreviated month", typ: DATETIME_PROPERTY, format: valueFormat{format: "2 Jan 2006", locale: "de"}, val: "5 Dez. 2024", want: time.Date(2024, 12, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "Test invalid datetime", typ: DATETIME_PROPERTY, format: valueFormat{format: "2006-01-02"}, val: "yesterday", wantErr: true},
+		{name: "Test currency symbol", typ: CURRENCY_PROPERTY, val: "£12.99", want: Money{Amount: "12.99", Code: "GBP"}},
+		{name: "Test currency code", typ: CURRENCY_PROPERTY, format: valueFormat{locale: "fr"}, val: "1 234,50 EUR", want: Money{Amount: "1234.50", Code: "EUR"}},
+		{name: "Test currency symbol next to the amount", typ: CURRENCY_PROPERTY, val: "NOW $19.99", want: Money{Amount: "19.99", Code: "USD"}},
+		{name: "Test currency code after a word of capitals", typ: CURRENCY_PROPERTY, val: "NEW 25 GBP", want: Money{Amount: "25", Code: "GBP"}},
+		{name: "Test currency word which is not a code", typ: CURRENCY_PROPERTY, format: valueFormat{locale: "fr", currency: "eur"}, val: "NOW 19,99", want: Money{Amount: "19.99", Code: "EUR"}},
+		{name: "Test currency default code", typ: CURRENCY_PROPERTY, format: valueFormat{currency: "ngn"}, val: "5,000", want: Money{Amount: "5000", Code: "NGN"}},
+		{name: "Test currency without code", typ: CURRENCY_PROPERTY, val: "5,000", wantErr: true},
+		{name: "Test url", typ: URL_PROPERTY, val: "../item/1", want: "http://example.com/item/1"},
+		{name: "Test invalid url", typ: URL_PROPERTY, val: "http://[::1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseValue(tt.typ, tt.format, tt.val, base)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseValue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("parseValue() error = %v, want ErrInvalidValue", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !tt.wantErr {
+				t.Errorf("parseValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractor_RichTypes(t *testing.T) {
+	Convey("given a schema with rich types", t, func() {
+		schema := SchemaFromString(`{
+			"css": [".product"],
+			"properties": [
+				{"id": "price", "type": "currency", "css": [".price"], "currency": "USD"},
+				{"id": "rating", "type": "float", "css": [".price"]},
+				{"id": "link", "type": "url", "css": ["h2 a", "href"]},
+				{"id": "released", "type": "datetime", "css": ["h2 a"]}
+			]
+		}`)
+		base, _ := url.Parse("http://example.com/products/")
+		ex := NewExtractor(schema).WithBaseURL(base)
+		Convey("values should be parsed and failures reported", func() {
+			rows, err := ex.ExtractRows(productDoc().Selection)
+			So(err, ShouldBeNil)
+			So(rows[0].Data["price"], ShouldResemble, Money{Amount: "42", Code: "USD"})
+			So(rows[0].Data["rating"], ShouldEqual, 42)
+			So(rows[0].Data["link"], ShouldEqual, "http://example.com/p/1")
+			So(rows[0].Data["released"], ShouldBeNil)
+			So(errors.Is(rows[0].Errors, ErrInvalidValue), ShouldBeTrue)
+			So(rows[0].Errors[0].Id, ShouldEqual, "released")
+		})
+	})
+}
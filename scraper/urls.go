@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// resolveURL resolves ref against base as described in RFC 3986, ref is returned as is when base is nil
+func resolveURL(base *url.URL, ref string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+	if base == nil {
+		return u.String(), nil
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
+// documentBase returns the url relative links of the document of node are resolved against, the
+// href of its <base> element resolved against docURL or docURL itself when there is none
+func documentBase(docURL *url.URL, node *goquery.Selection) *url.URL {
+	if node == nil || len(node.Nodes) == 0 {
+		return docURL
+	}
+	root := node.Nodes[0]
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	href, ok := baseHref(root)
+	if !ok {
+		return docURL
+	}
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return docURL
+	}
+	if docURL == nil {
+		if ref.IsAbs() {
+			return ref
+		}
+		return nil
+	}
+	return docURL.ResolveReference(ref)
+}
+
+// baseHref finds the href of the first <base> element, the body is not searched since <base> must be in the head
+func baseHref(n *html.Node) (string, bool) {
+	if n.Type == html.ElementNode {
+		switch n.Data {
+		case "base":
+			for _, a := range n.Attr {
+				if a.Key == "href" {
+					return a.Val, true
+				}
+			}
+		case "body":
+			return "", false
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href, ok := baseHref(c); ok {
+			return href, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,141 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/osiloke/grapple/mocks"
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/stretchr/testify/mock"
+)
+
+func Test_resolveURL(t *testing.T) {
+	base, _ := url.Parse("http://example.com/shop/list/page.html?page=2")
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{name: "Test absolute", ref: "https://other.com/a", want: "https://other.com/a"},
+		{name: "Test root relative", ref: "/item/1", want: "http://example.com/item/1"},
+		{name: "Test relative", ref: "item/1", want: "http://example.com/shop/list/item/1"},
+		{name: "Test parent relative", ref: "../item", want: "http://example.com/shop/item"},
+		{name: "Test query only", ref: "?page=3", want: "http://example.com/shop/list/page.html?page=3"},
+		{name: "Test protocol relative", ref: "//cdn.example.com/img.png", want: "http://cdn.example.com/img.png"},
+		{name: "Test fragment", ref: "#top", want: "http://example.com/shop/list/page.html?page=2#top"},
+		{name: "Test surrounding space", ref: " /item/2 ", want: "http://example.com/item/2"},
+		{name: "Test invalid", ref: "http://[::1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveURL(base, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("resolveURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var basePage = `<html>
+<head><base href="/static/v2/"></head>
+<body>
+<div class="item">
+<img src="img/1.png"/>
+<a href="../detail/1">one</a>
+<a href="//cdn.example.com/detail/2">two</a>
+<a class="next" href="?page=2">next</a>
+</div>
+</body>
+</html>`
+
+func TestDocumentBase(t *testing.T) {
+	Convey("given a page with a base element", t, func() {
+		doc, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(basePage))
+		pageURL, _ := url.Parse("http://example.com/list")
+		Convey("the base should be resolved against the page url", func() {
+			So(documentBase(pageURL, doc.Find("img")).String(), ShouldEqual, "http://example.com/static/v2/")
+		})
+		Convey("a relative base without a page url should be ignored", func() {
+			So(documentBase(nil, doc.Find("img")), ShouldBeNil)
+		})
+		Convey("links extracted from it should be resolved", func() {
+			schema := SchemaFromString(`{
+				"css": [".item"],
+				"properties": [
+					{"id": "image", "type": "imageurl", "css": ["img", "src"]},
+					{"id": "links", "type": "urllist", "css": ["a", "href"]},
+					{"id": "first", "type": "url", "css": ["a", "href"]}
+				]
+			}`)
+			rows, err := NewExtractor(schema).WithBaseURL(pageURL).ExtractRows(doc.Selection)
+			So(err, ShouldBeNil)
+			So(rows[0].Errors, ShouldBeEmpty)
+			So(rows[0].Data["image"], ShouldEqual, "http://example.com/static/v2/img/1.png")
+			So(rows[0].Data["links"], ShouldResemble, []string{
+				"http://example.com/static/detail/1",
+				"http://cdn.example.com/detail/2",
+				"http://example.com/static/v2/?page=2",
+			})
+			So(rows[0].Data["first"], ShouldEqual, "http://example.com/static/detail/1")
+		})
+		Convey("page scrapers should resolve rows against the page url", func() {
+			p := NewPageScraper(nil, SchemaFromString(`{
+				"css": [".item"],
+				"next": ["a.next"],
+				"properties": [{"id": "image", "type": "imageurl", "css": ["img", "src"]}]
+			}`))
+			rows, err := GetPageRows(p, "http://example.com/list", []byte(basePage))
+			So(err, ShouldBeNil)
+			So(rows[0].(map[string]interface{})["image"], ShouldEqual, "http://example.com/static/v2/img/1.png")
+			next, err := p.GetNextURL("http://example.com/list", []byte(basePage))
+			So(err, ShouldBeNil)
+			So(next, ShouldEqual, "http://example.com/static/v2/?page=2")
+		})
+	})
+}
+
+var childListPage = `<html><body>
+<a class="child" href="../items/1?ref=list">one</a>
+<a class="child" href="//cdn.example.com/items/2">two</a>
+</body></html>`
+
+var childPage = `<html><body><h1>Item</h1></body></html>`
+
+func TestScrapeStreamChildURLs(t *testing.T) {
+	Convey("given a url list job on a nested page", t, func() {
+		list, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(childListPage))
+		list.Url, _ = url.Parse("http://example.com/shop/list/")
+		child, _ := goquery.NewDocumentFromReader(bytes.NewBufferString(childPage))
+		client := mocks.Client{}
+		client.On("GetBytesContext", Anything, AnythingOfType("string")).Return([]byte("127.0.0.1"), nil)
+		client.On("GetDocContext", Anything, "http://example.com/shop/list/").Return(list, nil)
+		client.On("GetDocContext", Anything, AnythingOfType("string")).Return(child, nil)
+		job := Job{
+			Name: "child urls",
+			URL:  "http://example.com/shop/list/",
+			JobSchema: SchemaFromString(`{
+				"type": "urllist",
+				"css": ["a.child", "href"],
+				"limit": 2,
+				"properties": [{"id": "item", "css": ["body"], "properties": [{"id": "title", "type": "string", "css": ["h1"]}]}]
+			}`),
+			Con: &client,
+		}
+		Convey("child urls should be resolved against the list page", func() {
+			rows, err := job.ScrapeStreamContext(context.Background())
+			So(err, ShouldBeNil)
+			for range rows {
+			}
+			client.AssertCalled(t, "GetDocContext", Anything, "http://example.com/shop/items/1?ref=list")
+			client.AssertCalled(t, "GetDocContext", Anything, "http://cdn.example.com/items/2")
+		})
+	})
+}
@@ -1,79 +1,110 @@
-package scraper
-
-import (
-	"encoding/json"
-	"regexp"
-	"strings"
-	"unicode"
-	"unicode/utf8"
-
-	"github.com/PuerkitoBio/goquery"
-	"github.com/apex/log"
-)
-
-// StringValFromCSSPath tries to get a string from a node
-func StringValFromCSSPath(path []string, node *goquery.Selection) (string, bool) {
-	var val string
-	if len(path) == 1 {
-		val = node.Text()
-	} else {
-		if v, ok := node.Attr(path[1]); ok {
-			val = v
-			if len(path) > 2 {
-				reg := path[2]
-				r, _ := regexp.Compile(reg)
-				m := r.FindStringSubmatch(val)
-				if len(m) > 1 {
-					val = m[1]
-				}
-			}
-		} else {
-			return "", false
-		}
-	}
-	val = stringMinifier(val)
-	return removeInvalidUtf(val), true
-}
-
-// SchemaFromString creates a schema from a json string
-func SchemaFromString(data string) *Schema {
-	var schema Schema
-	if err := json.Unmarshal([]byte(data), &schema); err != nil {
-		log.WithError(err).Error("Unable to load schema")
-		return nil
-	}
-	return &schema
-}
-func removeInvalidUtf(s string) string {
-	if !utf8.ValidString(s) {
-		v := make([]rune, 0, len(s))
-		for i, r := range s {
-			if r == utf8.RuneError {
-				_, size := utf8.DecodeRuneInString(s[i:])
-				if size == 1 {
-					continue
-				}
-			}
-			v = append(v, r)
-		}
-		return string(v)
-	}
-	return strings.TrimSpace(s)
-}
-
-//http://intogooglego.blogspot.com.ng/2015/05/day-6-string-minifier-remove-whitespaces.html
-func stringMinifier(in string) (out string) {
-	white := false
-	for _, c := range in {
-		if unicode.IsSpace(c) {
-			if !white {
-				out = out + " "
-			}
-			white = true
-		} else {
-			out = out + string(c)
-			white = false
-		}
-	}
-	return
-}
+package scraper
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/apex/log"
+)
+
+// StringValFromCSSPath tries to get a string from a node, the css[2] regular expression is compiled on
+// every call and ignored when it is invalid.
+//
+// Deprecated: use an Extractor, which compiles the paths of its schema once and reports invalid ones
+func StringValFromCSSPath(path []string, node *goquery.Selection) (string, bool) {
+	var re *regexp.Regexp
+	if len(path) > 2 {
+		re, _ = regexp.Compile(path[2])
+	}
+	return stringValFromPath(path, re, node)
+}
+
+// stringValFromPath is StringValFromCSSPath with the regular expression of the path already compiled
+func stringValFromPath(path []string, re *regexp.Regexp, node *goquery.Selection) (string, bool) {
+	val, ok := rawValFromPath(path, re, node)
+	if !ok {
+		return "", false
+	}
+	val = stringMinifier(val)
+	return removeInvalidUtf(val), true
+}
+
+// rawValFromPath is stringValFromPath without the whitespace and utf8 cleanup
+func rawValFromPath(path []string, re *regexp.Regexp, node *goquery.Selection) (string, bool) {
+	var val string
+	if len(path) < 2 || path[1] == "" {
+		val = node.Text()
+	} else {
+		if v, ok := node.Attr(path[1]); ok {
+			val = v
+		} else {
+			return "", false
+		}
+	}
+	if re != nil {
+		m := re.FindStringSubmatch(val)
+		if len(m) > 1 {
+			val = m[1]
+		}
+	}
+	return val, true
+}
+
+// SchemaFromString creates a schema from a json string
+func SchemaFromString(data string) *Schema {
+	var schema Schema
+	if err := json.Unmarshal([]byte(data), &schema); err != nil {
+		log.WithError(err).Error("Unable to load schema")
+		return nil
+	}
+	return &schema
+}
+
+// CompileSchema creates a schema from a json string and validates it
+func CompileSchema(data string) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal([]byte(data), &schema); err != nil {
+		return nil, err
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+func removeInvalidUtf(s string) string {
+	if !utf8.ValidString(s) {
+		v := make([]rune, 0, len(s))
+		for i, r := range s {
+			if r == utf8.RuneError {
+				_, size := utf8.DecodeRuneInString(s[i:])
+				if size == 1 {
+					continue
+				}
+			}
+			v = append(v, r)
+		}
+		return string(v)
+	}
+	return strings.TrimSpace(s)
+}
+
+//http://intogooglego.blogspot.com.ng/2015/05/day-6-string-minifier-remove-whitespaces.html
+func stringMinifier(in string) (out string) {
+	white := false
+	for _, c := range in {
+		if unicode.IsSpace(c) {
+			if !white {
+				out = out + " "
+			}
+			white = true
+		} else {
+			out = out + string(c)
+			white = false
+		}
+	}
+	return
+}